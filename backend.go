@@ -0,0 +1,130 @@
+package improc
+
+import (
+	"errors"
+	"image"
+)
+
+// ErrUnsupported is returned when an operation or an
+// output format isn't supported by the active Backend
+var ErrUnsupported = errors.New("operation not supported by image backend")
+
+// Backend is an image processing implementation which the
+// converter delegates all of its image operations to. The
+// backend is selected at build time: ImageMagick by default,
+// or a pure Go implementation when building with the
+// `purego` tag
+type Backend interface {
+	// Ping reads the header of an image blob, without
+	// decoding any pixel data
+	Ping(blob []byte) (*SourceInfo, error)
+
+	// SetLimits guards decoding against sources exceeding
+	// the input limits, as a backstop to the checks of Ping
+	SetLimits(limits *InputLimits) error
+
+	// NewImage creates an empty image to read a source into
+	NewImage() Image
+
+	// Terminate releases all resources held by the backend
+	Terminate()
+}
+
+// SourceInfo describes a source image, as read from the
+// header of its blob. Width and Height are the largest of
+// all frames, including their canvas
+type SourceInfo struct {
+	Width  uint
+	Height uint
+	Frames uint
+}
+
+// Image is a single image owned by a Backend, on which the
+// converter applies resizing, cropping, etc
+type Image interface {
+	// Read decodes an image blob into the image
+	Read(blob []byte) error
+
+	// Format returns the format of the source image, such as "GIF"
+	Format() string
+
+	// Frames returns the number of frames or pages in the image,
+	// which all are processed by the other operations
+	Frames() uint
+
+	// SelectFrames keeps the frames in the range from first
+	// to last, and discards the others. Animation frames are
+	// coalesced, so that every selected frame is complete
+	SelectFrames(first, last uint) error
+
+	// Width returns the current image width in pixels
+	Width() uint
+
+	// Height returns the current image height in pixels
+	Height() uint
+
+	// AutoOrient rotates and flips the image according to
+	// its EXIF orientation, so that it's displayed top-left
+	AutoOrient() error
+
+	// ConvertProfile transforms the image from its embedded ICC
+	// profile into the target profile. Images without an embedded
+	// profile are assumed to be sRGB, or to match cmykDefault when
+	// they are CMYK images and cmykDefault is set
+	ConvertProfile(target, cmykDefault []byte) error
+
+	// Rotate rotates the image clockwise by the given degrees,
+	// growing the canvas to fit it and filling the uncovered
+	// corners with the background color
+	Rotate(degrees float64, background Color) error
+
+	// Flip mirrors the image horizontally, from left to right
+	Flip() error
+
+	// Flop mirrors the image vertically, from top to bottom
+	Flop() error
+
+	// Resize scales the image to the given dimensions,
+	// using the given resampling filter
+	Resize(width, height uint, filter Filter) error
+
+	// Extent resizes the canvas to the given dimensions, where
+	// x and y is the offset of the canvas relative to the image
+	Extent(width, height uint, x, y int) error
+
+	// Crop extracts a region of the given dimensions, where
+	// x and y is the offset of the region relative to the image
+	Crop(width, height uint, x, y int) error
+
+	// TrimBox returns the bounds of the first frame without its
+	// uniform borders, where fuzz is the color distance in percent
+	// within which a pixel is considered part of the border
+	TrimBox(fuzz float64) (image.Rectangle, error)
+
+	// Background applies a background color to the visible
+	// canvas, with respect to the output compression
+	Background(color Color, compression Compression) error
+
+	// Text draws a block of text on top of the image
+	Text(tb *TextBlock) error
+
+	// Strip removes all metadata from the image
+	Strip() error
+
+	// Profile returns a metadata profile, such as "icc"
+	// or "exif", or nil if the image doesn't have it
+	Profile(name string) []byte
+
+	// SetProfile adds a metadata profile to the image
+	SetProfile(name string, profile []byte) error
+
+	// Pixels returns the pixels of the first frame. The
+	// returned image must not be modified
+	Pixels() (*image.NRGBA, error)
+
+	// Encode returns the image blob in the given compression
+	Encode(quality uint, compression Compression, options *EncoderOptions) ([]byte, error)
+
+	// Destroy releases all resources held by the image
+	Destroy()
+}
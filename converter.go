@@ -1,41 +1,290 @@
 package improc
 
 import (
-	"gopkg.in/gographics/imagick.v3/imagick"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/sync/semaphore"
 )
 
+// ErrCanceled is returned when a conversion is aborted because
+// its context was cancelled or its deadline was exceeded
+var ErrCanceled = errors.New("image conversion canceled")
+
+// ErrSizeUnreachable is returned when no output within
+// OutputSpec.MaxBytes can be encoded
+var ErrSizeUnreachable = errors.New("output size budget unreachable")
+
+// Result describes the encoded output image
+type Result struct {
+	// Quality is the encoder quality used
+	Quality uint
+
+	// Lossless tells if WebP output was encoded losslessly
+	Lossless bool
+
+	// Size is the output size in bytes
+	Size int
+
+	// Score is the SSIM score of the output compared with the
+	// processed image before encoding, from 0 to 1. It's only
+	// computed when OutputSpec.TargetScore is set
+	Score float64
+}
+
+// DefaultMaxDevicePixelRatio is the highest device pixel
+// ratio applied, unless WithMaxDevicePixelRatio is used
+const DefaultMaxDevicePixelRatio = 3
+
 // ImageConverter handles output specifications and
 // processes images to match the desired specification
-type ImageConverter struct{}
+type ImageConverter struct {
+	backend     Backend
+	limiter     *limiter
+	limits      *InputLimits
+	cmykProfile []byte
+	maxDPR      float64
 
-// NewImageConverter creates a new converter
-// which uses Imagick C bindings library
-func NewImageConverter() *ImageConverter {
-	imagick.Initialize()
+	// err is an error from applying the options,
+	// which is returned from every conversion
+	err error
+}
+
+// Option configures an ImageConverter
+type Option func(*ImageConverter)
+
+// WithMaxConcurrency limits the number of conversions
+// processed at the same time. Further conversions wait
+// for a free slot, unless WithFailFast is used
+func WithMaxConcurrency(n int) Option {
+	return func(c *ImageConverter) {
+		if n > 0 {
+			c.limiter.slots = semaphore.NewWeighted(int64(n))
+		}
+	}
+}
+
+// WithPixelBudget limits the total number of source pixels
+// decoded at the same time, across all conversions. Sources
+// larger than the whole budget fail with ErrOverloaded
+func WithPixelBudget(pixels int64) Option {
+	return func(c *ImageConverter) {
+		if pixels > 0 {
+			c.limiter.pixels = semaphore.NewWeighted(pixels)
+			c.limiter.pixelBudget = pixels
+		}
+	}
+}
+
+// WithFailFast makes conversions fail with ErrOverloaded
+// instead of waiting, when the concurrency limit or the
+// pixel budget is exhausted
+func WithFailFast() Option {
+	return func(c *ImageConverter) {
+		c.limiter.failFast = true
+	}
+}
+
+// WithCMYKProfile sets the ICC profile assumed for CMYK
+// images without an embedded profile. Without it, such
+// images are converted to sRGB without a profile
+func WithCMYKProfile(profile []byte) Option {
+	return func(c *ImageConverter) {
+		c.cmykProfile = profile
+	}
+}
+
+// WithMaxDevicePixelRatio sets the highest device pixel ratio
+// applied, higher ratios of an OutputSpec are lowered to it
+func WithMaxDevicePixelRatio(ratio float64) Option {
+	return func(c *ImageConverter) {
+		if ratio >= 1 {
+			c.maxDPR = ratio
+		}
+	}
+}
+
+// NewImageConverter creates a new converter which uses
+// Imagick C bindings library, or a pure Go implementation
+// when built with the `purego` tag
+func NewImageConverter(options ...Option) *ImageConverter {
+	c := &ImageConverter{
+		backend: newBackend(),
+		limiter: &limiter{},
+		maxDPR:  DefaultMaxDevicePixelRatio,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
 
-	return &ImageConverter{}
+	return c
 }
 
 // Apply takes an aoutput specification and processes
 // the incoming image blob accordingly
 func (c *ImageConverter) Apply(blob []byte, spec *OutputSpec) ([]byte, error) {
-	h := newHandler()
-	defer h.destroy()
+	return c.ApplyContext(context.Background(), blob, spec)
+}
+
+// ApplyContext works like Apply, but stops processing and
+// returns ErrCanceled as soon as ctx is done
+func (c *ImageConverter) ApplyContext(ctx context.Context, blob []byte, spec *OutputSpec) ([]byte, error) {
+	var output bytes.Buffer
 
-	var err error
+	if _, err := c.apply(ctx, blob, &output, spec); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// ApplyStream takes an output specification and processes
+// the image read from r accordingly, writing the result to w.
+// Processing stops and ErrCanceled is returned as soon as
+// ctx is done. The returned Result describes the output.
+// The source is fully buffered in memory before decoding;
+// set InputLimits.MaxFileSize to cap how much is read
+func (c *ImageConverter) ApplyStream(ctx context.Context, r io.Reader, w io.Writer, spec *OutputSpec) (*Result, error) {
+	if err := CheckContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if c.limits != nil && c.limits.MaxFileSize > 0 {
+		// Read one byte past the limit, to tell if it's exceeded
+		r = io.LimitReader(r, c.limits.MaxFileSize+1)
+	}
+
+	blob, err := ioutil.ReadAll(&contextReader{ctx, r})
+	if err != nil {
+		if ctxErr := CheckContext(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		return nil, err
+	}
+
+	return c.apply(ctx, blob, w, spec)
+}
+
+// Stats returns the number of conversions currently
+// being processed, and waiting to be processed
+func (c *ImageConverter) Stats() Stats {
+	return c.limiter.stats()
+}
+
+func (c *ImageConverter) apply(ctx context.Context, blob []byte, w io.Writer, spec *OutputSpec) (*Result, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c.limits != nil {
+		if err := c.limits.checkFileSize(int64(len(blob))); err != nil {
+			return nil, err
+		}
+	}
+
+	var pixels int64
+	if c.limiter.pixels != nil || (c.limits != nil && c.limits.hasDimensions()) {
+		info, err := c.backend.Ping(blob)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.limits != nil {
+			if err := c.limits.check(info); err != nil {
+				return nil, err
+			}
+		}
+
+		// Animations are decoded into full frames
+		pixels = int64(info.Width) * int64(info.Height) * int64(info.Frames)
+	}
+
+	release, err := c.limiter.acquire(ctx, pixels)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	spec = spec.withDevicePixelRatio(c.maxDPR)
+
+	h := newHandler(ctx, c.backend)
+	defer h.destroy()
 
 	err = h.fromBlob(blob)
 	if err != nil {
 		return nil, err
 	}
 
-	h.strip()
-	err = h.applyFormat(spec)
+	err = h.selectFrames(spec)
 	if err != nil {
 		return nil, err
 	}
 
-	h.applyBackground(spec.Background, spec.Compression)
+	// The orientation must be applied before stripping
+	// metadata, since it's read from the EXIF data
+	if !spec.NoAutoOrient {
+		err = h.autoOrient()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.Source != nil {
+		err = h.extractSource(spec.Source)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.Trim != nil {
+		err = h.trim(spec.Trim)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = h.transform(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.convertProfile(spec, c.cmykProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.strip(spec.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.EmbedProfile {
+		err = h.embedProfile(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.Packshot != nil {
+		err = h.applyPackshot(spec)
+	} else {
+		err = h.applyFormat(spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.applyBackground(spec.Background, spec.Compression)
+	if err != nil {
+		return nil, err
+	}
 
 	if spec.Text != nil {
 		if err = h.applyTextBlock(spec.Text); err != nil {
@@ -43,12 +292,55 @@ func (c *ImageConverter) Apply(blob []byte, spec *OutputSpec) ([]byte, error) {
 		}
 	}
 
-	bytes := h.bytes(spec.Quality, spec.Compression)
-
-	return bytes, nil
+	return h.write(w, spec)
 }
 
-// Destroy terminates the ImageMagick session
+// Destroy terminates the backend session, such as
+// the ImageMagick environment
 func (c *ImageConverter) Destroy() {
-	imagick.Terminate()
+	c.backend.Terminate()
+}
+
+// CheckContext returns an error wrapping ErrCanceled if
+// ctx is done, and nil otherwise
+func CheckContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return &canceledError{cause: err}
+	}
+
+	return nil
+}
+
+// canceledError matches ErrCanceled while still unwrapping
+// to the context error, so callers can tell a cancellation
+// from an exceeded deadline
+type canceledError struct {
+	cause error
+}
+
+func (e *canceledError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrCanceled, e.cause)
+}
+
+func (e *canceledError) Is(target error) bool {
+	return target == ErrCanceled
+}
+
+func (e *canceledError) Unwrap() error {
+	return e.cause
+}
+
+// contextReader stops reading from the underlying
+// reader as soon as the context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cr.r.Read(p)
 }
@@ -0,0 +1,644 @@
+package improc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testImage(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testJpegWithOrientation(t *testing.T, width, height int, orientation uint16) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A big endian TIFF header with a single IFD0 entry for the orientation tag
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(exif)+2))
+	app1 = append(app1, exif...)
+
+	blob := buf.Bytes()
+	return append(append(append([]byte{}, blob[:2]...), app1...), blob[2:]...)
+}
+
+func outputSize(t *testing.T, blob []byte) (int, int) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg.Width, cfg.Height
+}
+
+func Test_That_Apply_Resizes_To_Width_And_Keeps_Ratio(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("100x")
+	spec.Compression = Png
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Pads_Canvas_Without_Crop(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("100x100")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Crops_Canvas_With_Crop(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x100")
+	spec.Compression = Jpeg
+	spec.Crop = true
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Uses_Oriented_Dimensions(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("10x")
+	spec.Compression = Jpeg
+
+	output, err := converter.Apply(testJpegWithOrientation(t, 20, 10, 6), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 10, w)
+	assert.Equal(t, 20, h)
+}
+
+func Test_That_Apply_Skips_Orientation_With_NoAutoOrient(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("10x")
+	spec.Compression = Jpeg
+	spec.NoAutoOrient = true
+
+	output, err := converter.Apply(testJpegWithOrientation(t, 20, 10, 6), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 10, w)
+	assert.Equal(t, 5, h)
+}
+
+func Test_That_ApplyStream_Lowers_Quality_To_Fit_MaxBytes(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("200x200")
+	spec.Compression = Jpeg
+	spec.Quality = 100
+
+	var full bytes.Buffer
+	_, err := converter.ApplyStream(context.Background(), bytes.NewReader(testImage(t, 200, 200)), &full, spec)
+	assert.NoError(t, err)
+
+	spec.MaxBytes = full.Len() / 2
+
+	var output bytes.Buffer
+	result, err := converter.ApplyStream(context.Background(), bytes.NewReader(testImage(t, 200, 200)), &output, spec)
+
+	assert.NoError(t, err)
+	assert.True(t, result.Quality < 100)
+	assert.Equal(t, output.Len(), result.Size)
+	assert.True(t, result.Size <= spec.MaxBytes)
+}
+
+func Test_That_ApplyStream_Returns_ErrSizeUnreachable(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("200x200")
+	spec.Compression = Jpeg
+	spec.MaxBytes = 10
+
+	_, err := converter.ApplyStream(context.Background(), bytes.NewReader(testImage(t, 200, 200)), ioutil.Discard, spec)
+
+	assert.True(t, errors.Is(err, ErrSizeUnreachable))
+}
+
+func Test_That_ApplyStream_Returns_Smallest_Output_With_BestEffort(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("200x200")
+	spec.Compression = Jpeg
+	spec.MaxBytes = 10
+	spec.BestEffort = true
+
+	var output bytes.Buffer
+	result, err := converter.ApplyStream(context.Background(), bytes.NewReader(testImage(t, 200, 200)), &output, spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, output.Len(), result.Size)
+	assert.True(t, result.Quality < 10)
+}
+
+func Test_That_ApplyStream_Reaches_TargetScore(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("200x200")
+	spec.Compression = Jpeg
+	spec.TargetScore = 0.9
+
+	low, err := converter.ApplyStream(context.Background(), bytes.NewReader(testImage(t, 200, 200)), ioutil.Discard, spec)
+	assert.NoError(t, err)
+	assert.True(t, low.Score >= 0.9)
+
+	spec.TargetScore = 0.99
+
+	high, err := converter.ApplyStream(context.Background(), bytes.NewReader(testImage(t, 200, 200)), ioutil.Discard, spec)
+	assert.NoError(t, err)
+	assert.True(t, high.Score >= 0.99)
+	assert.True(t, high.Quality >= low.Quality)
+}
+
+func Test_That_Apply_Pads_To_Target_Size_With_FitContain(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x50")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitContain
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Crops_To_Target_Size_With_FitCover(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x50")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitCover
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Stretches_To_Target_Size_With_FitFill(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x50")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitFill
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Fits_Within_Target_Size_With_FitInside(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x50")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitInside
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 25, h)
+}
+
+func Test_That_Apply_Covers_Target_Size_With_FitOutside(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x50")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitOutside
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Upscales_With_UpscaleAllow(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitContain
+	spec.Upscale = UpscaleAllow
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 400, w)
+	assert.Equal(t, 200, h)
+}
+
+func Test_That_Apply_Keeps_Source_Size_With_UpscaleClamp(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitContain
+	spec.Upscale = UpscaleClamp
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Pads_Source_To_Target_Size_With_UpscalePad(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitContain
+	spec.Upscale = UpscalePad
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 400, w)
+	assert.Equal(t, 200, h)
+}
+
+func Test_That_Apply_Clamps_Target_Box_To_Source_Size_With_UpscaleClamp(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x400")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitContain
+	spec.Upscale = UpscaleClamp
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Pads_Target_Box_With_UpscalePad(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x400")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitContain
+	spec.Upscale = UpscalePad
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 400, w)
+	assert.Equal(t, 400, h)
+}
+
+func Test_That_Apply_Clamps_Cover_To_Source_Size_With_UpscaleClamp(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x400")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitCover
+	spec.Upscale = UpscaleClamp
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Clamps_Inside_To_Source_Size_With_UpscaleClamp(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x400")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitInside
+	spec.Upscale = UpscaleClamp
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Pads_Inside_To_Target_Aspect_With_UpscalePad(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("400x400")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitInside
+	spec.Upscale = UpscalePad
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 400, w)
+	assert.Equal(t, 200, h)
+}
+
+func Test_That_Apply_Downscales_With_UpscaleClamp(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+	spec.Fit = FitContain
+	spec.Upscale = UpscaleClamp
+
+	output, err := converter.Apply(testImage(t, 100, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 25, h)
+}
+
+func Test_That_Apply_Multiplies_Dimensions_By_DevicePixelRatio(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.DevicePixelRatio = 2
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Limits_DevicePixelRatio(t *testing.T) {
+	converter := NewImageConverter(WithMaxDevicePixelRatio(1.5))
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.DevicePixelRatio = 4
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, _ := outputSize(t, output)
+	assert.Equal(t, 75, w)
+}
+
+func Test_That_Apply_Does_Not_Shrink_Dimensions_By_DevicePixelRatio_Below_One(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.DevicePixelRatio = 0.01
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 25, h)
+}
+
+func Test_That_Apply_Pads_With_Anchor_Offset(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("100x100@0,1+10")
+	spec.Compression = Png
+	spec.Background = ColorTransparent
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(output))
+	assert.NoError(t, err)
+	for y, opaque := range map[int]bool{35: false, 45: true, 85: true, 95: false} {
+		_, _, _, a := img.At(50, y).RGBA()
+		assert.Equal(t, opaque, a > 0, "row %d", y)
+	}
+}
+
+func Test_That_Apply_Extracts_Source_Rect_Before_Resizing(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Source = &Rect{X: 10, Y: 10, Width: 50, Height: 40}
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 40, h)
+}
+
+func Test_That_Apply_Extracts_Relative_Source_Rect(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("100x")
+	spec.Compression = Png
+	spec.Source = &Rect{X: 0.5, Y: 0, Width: 0.5, Height: 1, Relative: true}
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Extracts_Relative_Source_Rect_From_Odd_Dimensions(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("x50")
+	spec.Compression = Png
+	spec.Source = &Rect{X: 0.5, Y: 0, Width: 0.5, Height: 1, Relative: true}
+
+	output, err := converter.Apply(testImage(t, 101, 50), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Returns_Error_For_Source_Rect_Outside_Image(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Source = &Rect{X: 150, Y: 0, Width: 100, Height: 100}
+
+	_, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.Error(t, err)
+}
+
+func Test_That_Apply_Keeps_Orientation_Without_Rotation(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Rotate = 0
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 25, h)
+}
+
+func Test_That_Apply_Rotates_Quarter_Turn(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Rotate = 90
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Rotates_Half_Turn(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Rotate = 180
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 25, h)
+}
+
+func Test_That_Apply_Rotates_Counterclockwise_For_Negative_Degrees(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Rotate = -90
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Rotates_By_Degrees_Modulo_Full_Turn(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Rotate = 450
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Grows_Canvas_For_Arbitrary_Rotation(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+	spec.Rotate = 45
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 50, h)
+}
+
+func Test_That_Apply_Returns_Error_For_Non_Finite_Rotation(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Png
+
+	for _, degrees := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		spec.Rotate = degrees
+
+		_, err := converter.Apply(testImage(t, 200, 100), spec)
+
+		assert.Error(t, err, degrees)
+	}
+}
+
+func Test_That_Apply_Flips_And_Flops(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("200x~flip~flop")
+	spec.Compression = Png
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(output))
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 200, w)
+	assert.Equal(t, 100, h)
+
+	// The test image is red towards the right, and green towards the bottom
+	r, g, _, _ := img.At(0, 0).RGBA()
+	assert.True(t, r>>8 > 190)
+	assert.True(t, g>>8 > 90)
+}
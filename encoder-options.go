@@ -0,0 +1,118 @@
+package improc
+
+// DefaultAvifQuality is a quality giving AVIF output comparable
+// to JPEG output at the default quality of 85, since the AVIF
+// encoder reaches the same visual quality at lower values
+const DefaultAvifQuality = 60
+
+// Subsampling is an Enum specifying the chroma
+// subsampling of JPEG output
+type Subsampling int
+
+const (
+	// SubsamplingDefault leaves the encoder default
+	SubsamplingDefault Subsampling = iota
+
+	// Subsampling420 halves the chroma resolution both
+	// horizontally and vertically
+	Subsampling420
+
+	// Subsampling422 halves the chroma resolution horizontally
+	Subsampling422
+
+	// Subsampling444 keeps the full chroma resolution,
+	// which keeps small colored text sharp
+	Subsampling444
+)
+
+func (s Subsampling) samplingFactor() string {
+	return [...]string{"", "2x2,1x1,1x1", "2x1,1x1,1x1", "1x1,1x1,1x1"}[s]
+}
+
+// PngFilter is an Enum specifying the row filter
+// used when compressing PNG output
+type PngFilter int
+
+const (
+	// PngFilterDefault leaves the encoder default
+	PngFilterDefault PngFilter = iota
+
+	// PngFilterNone applies no filter
+	PngFilterNone
+
+	// PngFilterSub filters against the pixel to the left
+	PngFilterSub
+
+	// PngFilterUp filters against the pixel above
+	PngFilterUp
+
+	// PngFilterAverage filters against the average of
+	// the pixels to the left and above
+	PngFilterAverage
+
+	// PngFilterPaeth uses the Paeth predictor
+	PngFilterPaeth
+
+	// PngFilterAdaptive picks a filter for each row
+	PngFilterAdaptive
+)
+
+// EncoderOptions holds format specific encoder settings,
+// which only apply when encoding to the matching format
+type EncoderOptions struct {
+	Jpeg JpegOptions
+	Png  PngOptions
+	WebP WebPOptions
+	Avif AvifOptions
+}
+
+// JpegOptions are encoder settings for JPEG output
+type JpegOptions struct {
+	// Progressive encodes a progressive JPEG
+	Progressive bool
+
+	// Subsampling sets the chroma subsampling
+	Subsampling Subsampling
+
+	// Optimize computes optimized Huffman tables
+	Optimize bool
+}
+
+// PngOptions are encoder settings for PNG output
+type PngOptions struct {
+	// CompressionLevel is the zlib compression level, from
+	// 1 (fastest) to 9 (smallest output). Zero leaves the
+	// encoder default
+	CompressionLevel uint
+
+	// Filter sets the row filter
+	Filter PngFilter
+}
+
+// WebPOptions are encoder settings for WebP output
+type WebPOptions struct {
+	// Lossless encodes a lossless WebP
+	Lossless bool
+
+	// Method trades encoding time against output size, from 1
+	// (fastest) to 6 (slowest, smallest output). Zero leaves
+	// the encoder default
+	Method uint
+
+	// NearLossless enables near lossless preprocessing of
+	// lossless output, from 1 (most preprocessing) to 99.
+	// Zero leaves it disabled
+	NearLossless uint
+
+	// AlphaQuality is the quality of the alpha channel, from
+	// 1 to 100. Zero leaves the encoder default
+	AlphaQuality uint
+}
+
+// AvifOptions are encoder settings for AVIF output
+type AvifOptions struct {
+	// Speed trades encoding time against output size, from 1
+	// (slowest, smallest output) to 9 (fastest). Zero leaves
+	// the encoder default
+	Speed uint
+}
@@ -26,7 +26,7 @@ func (h *httpapi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	b, err := h.conv.Read(r)
 	if err != nil {
 		log.Printf("could not handle request: %v\n", err)
-		w.WriteHeader(400)
+		w.WriteHeader(httpimproc.StatusCode(err))
 		return
 	}
 
@@ -0,0 +1,162 @@
+package improc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+const (
+	exifTagOrientation = 0x0112
+	exifTagArtist      = 0x013B
+	exifTagCopyright   = 0x8298
+
+	tiffTypeASCII = 2
+	tiffTypeShort = 3
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// tiffEntry is a single IFD entry of a TIFF structure, where
+// data holds the entry value regardless of where it's stored
+type tiffEntry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	data  []byte
+}
+
+// readIFD0 reads the entries of the first IFD in a TIFF
+// structure, such as the payload of an EXIF profile
+func readIFD0(tiff []byte) (binary.ByteOrder, []tiffEntry, bool) {
+	if len(tiff) < 8 {
+		return nil, nil, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return nil, nil, false
+	}
+
+	var entries []tiffEntry
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		offset := ifd + 2 + n*12
+		if offset+12 > len(tiff) {
+			return nil, nil, false
+		}
+
+		entry := tiffEntry{
+			tag:   order.Uint16(tiff[offset:]),
+			kind:  order.Uint16(tiff[offset+2:]),
+			count: order.Uint32(tiff[offset+4:]),
+			data:  tiff[offset+8 : offset+12],
+		}
+
+		// ASCII values longer than 4 bytes are stored
+		// elsewhere, with the value field as the offset
+		if entry.kind == tiffTypeASCII && entry.count <= 4 {
+			entry.data = entry.data[:entry.count]
+		} else if entry.kind == tiffTypeASCII {
+			start := int(order.Uint32(tiff[offset+8:]))
+			end := start + int(entry.count)
+			if start < 0 || end > len(tiff) || end < start {
+				continue
+			}
+
+			entry.data = tiff[start:end]
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return order, entries, true
+}
+
+// tiffOrientation returns the orientation tag of a TIFF
+// structure, or 1 (top-left) when the tag can't be found
+func tiffOrientation(tiff []byte) int {
+	order, entries, ok := readIFD0(tiff)
+	if !ok {
+		return 1
+	}
+
+	for _, e := range entries {
+		if e.tag == exifTagOrientation {
+			if e.kind != tiffTypeShort || len(e.data) < 2 {
+				return 1
+			}
+
+			o := int(order.Uint16(e.data))
+			if o < 1 || o > 8 {
+				return 1
+			}
+
+			return o
+		}
+	}
+
+	return 1
+}
+
+// exifRights rebuilds an EXIF profile keeping only the
+// artist and copyright fields. It returns nil when the
+// profile doesn't contain any of them
+func exifRights(profile []byte) []byte {
+	hasHeader := bytes.HasPrefix(profile, exifHeader)
+	tiff := bytes.TrimPrefix(profile, exifHeader)
+
+	_, entries, ok := readIFD0(tiff)
+	if !ok {
+		return nil
+	}
+
+	var kept []tiffEntry
+	for _, e := range entries {
+		if (e.tag == exifTagArtist || e.tag == exifTagCopyright) && e.kind == tiffTypeASCII {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].tag < kept[j].tag })
+
+	order := binary.BigEndian
+	ifdSize := 2 + len(kept)*12 + 4
+	out := make([]byte, 8+ifdSize)
+	copy(out, "MM\x00\x2A")
+	order.PutUint32(out[4:], 8)
+	order.PutUint16(out[8:], uint16(len(kept)))
+
+	for n, e := range kept {
+		offset := 10 + n*12
+		order.PutUint16(out[offset:], e.tag)
+		order.PutUint16(out[offset+2:], e.kind)
+		order.PutUint32(out[offset+4:], uint32(len(e.data)))
+
+		if len(e.data) <= 4 {
+			copy(out[offset+8:offset+12], e.data)
+		} else {
+			order.PutUint32(out[offset+8:], uint32(len(out)))
+			out = append(out, e.data...)
+		}
+	}
+
+	if hasHeader {
+		return append(append([]byte{}, exifHeader...), out...)
+	}
+
+	return out
+}
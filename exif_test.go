@@ -0,0 +1,76 @@
+package improc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testTIFF(order binary.ByteOrder, entries ...tiffEntry) []byte {
+	tiff := make([]byte, 10+len(entries)*12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II\x2A\x00")
+	} else {
+		copy(tiff, "MM\x00\x2A")
+	}
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], uint16(len(entries)))
+
+	for n, e := range entries {
+		offset := 10 + n*12
+		order.PutUint16(tiff[offset:], e.tag)
+		order.PutUint16(tiff[offset+2:], e.kind)
+		order.PutUint32(tiff[offset+4:], e.count)
+
+		if len(e.data) <= 4 {
+			copy(tiff[offset+8:], e.data)
+		} else {
+			order.PutUint32(tiff[offset+8:], uint32(len(tiff)))
+			tiff = append(tiff, e.data...)
+		}
+	}
+
+	return tiff
+}
+
+func Test_That_TiffOrientation_Reads_Little_Endian_Orientation(t *testing.T) {
+	tiff := testTIFF(binary.LittleEndian, tiffEntry{tag: exifTagOrientation, kind: tiffTypeShort, count: 1, data: []byte{6, 0}})
+
+	assert.Equal(t, 6, tiffOrientation(tiff))
+}
+
+func Test_That_TiffOrientation_Defaults_To_TopLeft(t *testing.T) {
+	assert.Equal(t, 1, tiffOrientation([]byte("not a tiff")))
+}
+
+func Test_That_TiffOrientation_Defaults_To_TopLeft_For_Malformed_Orientation(t *testing.T) {
+	tiff := testTIFF(binary.LittleEndian, tiffEntry{tag: exifTagOrientation, kind: tiffTypeASCII, count: 0})
+
+	assert.Equal(t, 1, tiffOrientation(tiff))
+}
+
+func Test_That_ExifRights_Keeps_Only_Artist_And_Copyright(t *testing.T) {
+	artist := []byte("Jane Doe\x00")
+	copyright := []byte("(c)\x00")
+	tiff := testTIFF(binary.LittleEndian,
+		tiffEntry{tag: exifTagOrientation, kind: tiffTypeShort, count: 1, data: []byte{6, 0}},
+		tiffEntry{tag: exifTagArtist, kind: tiffTypeASCII, count: uint32(len(artist)), data: artist},
+		tiffEntry{tag: exifTagCopyright, kind: tiffTypeASCII, count: uint32(len(copyright)), data: copyright},
+	)
+
+	result := exifRights(append(append([]byte{}, exifHeader...), tiff...))
+
+	assert.Equal(t, exifHeader, result[:len(exifHeader)])
+	_, entries, ok := readIFD0(result[len(exifHeader):])
+	assert.True(t, ok)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, artist, entries[0].data)
+	assert.Equal(t, copyright, entries[1].data)
+}
+
+func Test_That_ExifRights_Returns_Nil_Without_Rights_Fields(t *testing.T) {
+	tiff := testTIFF(binary.BigEndian, tiffEntry{tag: exifTagOrientation, kind: tiffTypeShort, count: 1, data: []byte{0, 6}})
+
+	assert.Nil(t, exifRights(tiff))
+}
@@ -0,0 +1,76 @@
+package improc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter is an Enum specifying the resampling
+// filter used when resizing an image
+type Filter int
+
+const (
+	// FilterLanczos2 is a two-lobed Lanczos filter, sharp
+	// with little ringing. It's the default filter
+	FilterLanczos2 Filter = iota
+
+	// FilterNearest picks the nearest source pixel, keeping
+	// hard edges such as in pixel art
+	FilterNearest
+
+	// FilterBox averages the source pixels covered
+	// by each output pixel
+	FilterBox
+
+	// FilterTriangle is a bilinear filter, fast
+	// and somewhat blurry
+	FilterTriangle
+
+	// FilterHermite is a smooth cubic filter
+	FilterHermite
+
+	// FilterCatmullRom is a sharp cubic filter
+	FilterCatmullRom
+
+	// FilterMitchell is a cubic filter balancing
+	// blurring and ringing
+	FilterMitchell
+
+	// FilterLanczos3 is a three-lobed Lanczos
+	// filter, sharper than FilterLanczos2
+	FilterLanczos3
+
+	// FilterGaussian is a blurry filter, without ringing
+	FilterGaussian
+)
+
+var filterNames = [...]string{"lanczos2", "nearest", "box", "triangle", "hermite", "catmullrom", "mitchell", "lanczos3", "gaussian"}
+
+func (f Filter) String() string {
+	return filterNames[f]
+}
+
+// ParseFilter returns the Filter with the given name, such as
+// "nearest" or "lanczos3". Dashes in the name are ignored
+func ParseFilter(raw string) (Filter, error) {
+	name := strings.Replace(strings.ToLower(raw), "-", "", -1)
+
+	switch name {
+	case "point":
+		return FilterNearest, nil
+	case "linear", "bilinear":
+		return FilterTriangle, nil
+	case "catrom", "bicubic":
+		return FilterCatmullRom, nil
+	case "lanczos":
+		return FilterLanczos3, nil
+	}
+
+	for i, filterName := range filterNames {
+		if name == filterName {
+			return Filter(i), nil
+		}
+	}
+
+	return FilterLanczos2, fmt.Errorf("the filter %s is not valid", raw)
+}
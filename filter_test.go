@@ -0,0 +1,71 @@
+package improc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_ParseFilter_Parses_Filter_Names(t *testing.T) {
+	names := map[string]Filter{
+		"lanczos2":    FilterLanczos2,
+		"nearest":     FilterNearest,
+		"catmull-rom": FilterCatmullRom,
+		"mitchell":    FilterMitchell,
+		"gaussian":    FilterGaussian,
+	}
+
+	for raw, expected := range names {
+		filter, err := ParseFilter(raw)
+
+		assert.NoError(t, err, raw)
+		assert.Equal(t, expected, filter, raw)
+	}
+}
+
+func Test_That_ParseFilter_Parses_Filter_Aliases(t *testing.T) {
+	aliases := map[string]Filter{
+		"point":    FilterNearest,
+		"bilinear": FilterTriangle,
+		"lanczos":  FilterLanczos3,
+	}
+
+	for raw, expected := range aliases {
+		filter, err := ParseFilter(raw)
+
+		assert.NoError(t, err, raw)
+		assert.Equal(t, expected, filter, raw)
+	}
+}
+
+func Test_That_ParseFilter_Ignores_Case(t *testing.T) {
+	filter, err := ParseFilter("Box")
+
+	assert.NoError(t, err)
+	assert.Equal(t, FilterBox, filter)
+}
+
+func Test_That_ParseFilter_Returns_Error_For_Unknown_Filter(t *testing.T) {
+	_, err := ParseFilter("sharpest")
+
+	assert.Error(t, err)
+}
+
+func Test_That_Apply_Resizes_With_Every_Filter(t *testing.T) {
+	converter := NewImageConverter()
+
+	for filter := FilterLanczos2; filter <= FilterGaussian; filter++ {
+		t.Run(filter.String(), func(t *testing.T) {
+			spec, _ := ParseOutputSpec("50x")
+			spec.Compression = Png
+			spec.Filter = filter
+
+			output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+			assert.NoError(t, err)
+			w, h := outputSize(t, output)
+			assert.Equal(t, 50, w)
+			assert.Equal(t, 25, h)
+		})
+	}
+}
@@ -4,5 +4,7 @@ go 1.13
 
 require (
 	github.com/stretchr/testify v1.4.0
+	golang.org/x/image v0.0.0-20200119044424-58c23975cae1
+	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
 	gopkg.in/gographics/imagick.v3 v3.2.0
 )
@@ -1,6 +1,10 @@
 package httpimproc
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"net/http"
 
 	improc "github.com/ourstudio-se/go-image-processor/v2"
@@ -10,22 +14,51 @@ import (
 // when parsing a request, to extract the
 // information needed to do image processing
 type ParameterMap struct {
-	SourceURL      string
-	Width          string
-	Height         string
-	Crop           string
-	Quality        string
-	Spec           string
-	AnchorX        string
-	AnchorY        string
-	Compression    string
-	TextValue      string
-	TextFont       string
-	TextSize       string
-	TextForeground string
-	TextBackground string
-	TextAnchor     string
-	Background     string
+	SourceURL        string
+	Width            string
+	Height           string
+	Crop             string
+	Quality          string
+	Spec             string
+	AnchorX          string
+	AnchorY          string
+	FocusX           string
+	FocusY           string
+	Compression      string
+	TextValue        string
+	TextFont         string
+	TextSize         string
+	TextForeground   string
+	TextBackground   string
+	TextAnchor       string
+	Background       string
+	AutoOrient       string
+	Metadata         string
+	EmbedProfile     string
+	Frames           string
+	JpegProgressive  string
+	JpegSubsampling  string
+	JpegOptimize     string
+	PngLevel         string
+	PngFilter        string
+	WebPLossless     string
+	WebPMethod       string
+	WebPNearLossless string
+	WebPAlphaQuality string
+	AvifSpeed        string
+	MaxBytes         string
+	BestEffort       string
+	TargetScore      string
+	Filter           string
+	Fit              string
+	Upscale          string
+	DevicePixelRatio string
+	SourceRect       string
+	Trim             string
+	Packshot         string
+	Rotate           string
+	Flip             string
+	Flop             string
 }
 
 // DefaultParameterMap returns a ParameterMap with
@@ -33,22 +66,51 @@ type ParameterMap struct {
 // when parsing a request
 func DefaultParameterMap() *ParameterMap {
 	return &ParameterMap{
-		SourceURL:      "url",
-		Width:          "width",
-		Height:         "height",
-		Crop:           "crop",
-		Quality:        "quality",
-		Spec:           "spec",
-		AnchorX:        "anchorx",
-		AnchorY:        "anchory",
-		Compression:    "output",
-		TextValue:      "text:value",
-		TextFont:       "text:font",
-		TextSize:       "text:size",
-		TextForeground: "text:foreground",
-		TextBackground: "text:background",
-		TextAnchor:     "text:anchor",
-		Background:     "background",
+		SourceURL:        "url",
+		Width:            "width",
+		Height:           "height",
+		Crop:             "crop",
+		Quality:          "quality",
+		Spec:             "spec",
+		AnchorX:          "anchorx",
+		AnchorY:          "anchory",
+		FocusX:           "focusx",
+		FocusY:           "focusy",
+		Compression:      "output",
+		TextValue:        "text:value",
+		TextFont:         "text:font",
+		TextSize:         "text:size",
+		TextForeground:   "text:foreground",
+		TextBackground:   "text:background",
+		TextAnchor:       "text:anchor",
+		Background:       "background",
+		AutoOrient:       "autoorient",
+		Metadata:         "metadata",
+		EmbedProfile:     "embedprofile",
+		Frames:           "frames",
+		JpegProgressive:  "jpeg:progressive",
+		JpegSubsampling:  "jpeg:subsampling",
+		JpegOptimize:     "jpeg:optimize",
+		PngLevel:         "png:level",
+		PngFilter:        "png:filter",
+		WebPLossless:     "webp:lossless",
+		WebPMethod:       "webp:method",
+		WebPNearLossless: "webp:nearlossless",
+		WebPAlphaQuality: "webp:alphaquality",
+		AvifSpeed:        "avif:speed",
+		MaxBytes:         "maxbytes",
+		BestEffort:       "besteffort",
+		TargetScore:      "score",
+		Filter:           "filter",
+		Fit:              "fit",
+		Upscale:          "upscale",
+		DevicePixelRatio: "dpr",
+		SourceRect:       "rect",
+		Trim:             "trim",
+		Packshot:         "packshot",
+		Rotate:           "rotate",
+		Flip:             "flip",
+		Flop:             "flop",
 	}
 }
 
@@ -62,17 +124,32 @@ type HTTPImageConverter struct {
 // NewHTTPImageConverter instantiates a new ImageConverter
 // which is able to parse HTTP requests and process
 // images accordingly
-func NewHTTPImageConverter() *HTTPImageConverter {
+func NewHTTPImageConverter(options ...improc.Option) *HTTPImageConverter {
 	return &HTTPImageConverter{
-		Converter:    improc.NewImageConverter(),
+		Converter:    improc.NewImageConverter(options...),
 		ParemeterMap: DefaultParameterMap(),
 	}
 }
 
 // Read is the handler function for a HTTP request, and
 // returns the raw image blob after the image has been
-// processed
+// processed. Fetching and processing stop as soon as
+// the request context is done
 func (hic *HTTPImageConverter) Read(r *http.Request) ([]byte, error) {
+	var output bytes.Buffer
+
+	if _, err := hic.Stream(&output, r); err != nil {
+		return nil, err
+	}
+
+	return output.Bytes(), nil
+}
+
+// Stream is the handler function for a HTTP request, and
+// streams the source image through the converter, writing
+// the processed image to w. The returned Result describes
+// the output, such as the quality chosen for a size budget
+func (hic *HTTPImageConverter) Stream(w io.Writer, r *http.Request) (*improc.Result, error) {
 	pmap := hic.ParemeterMap
 	if pmap == nil {
 		pmap = DefaultParameterMap()
@@ -84,15 +161,39 @@ func (hic *HTTPImageConverter) Read(r *http.Request) ([]byte, error) {
 	}
 
 	reader := NewURLReader(preq.Source)
-	b, err := reader.ReadBlob()
+	body, err := reader.Open(r.Context())
 	if err != nil {
 		return nil, err
 	}
 
-	output, err := hic.Converter.Apply(b, preq.OutputSpec)
-	if err != nil {
-		return nil, err
+	defer body.Close()
+
+	return hic.Converter.ApplyStream(r.Context(), body, w, preq.OutputSpec)
+}
+
+// StatusClientClosedRequest is the non-standard status code
+// used when the client went away before a response was written
+const StatusClientClosedRequest = 499
+
+// StatusCode returns the HTTP status code which best describes
+// an error returned from Read or Stream
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, improc.ErrInputTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, improc.ErrOverloaded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrFetchFailed):
+		// Checked before the deadline, since a fetch timing
+		// out unwraps to context.DeadlineExceeded as well
+		return http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusRequestTimeout
+	case errors.Is(err, improc.ErrCanceled):
+		return StatusClientClosedRequest
 	}
 
-	return output, nil
+	return http.StatusBadRequest
 }
@@ -0,0 +1,57 @@
+package httpimproc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	improc "github.com/ourstudio-se/go-image-processor/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_StatusCode_Returns_OK_Without_Error(t *testing.T) {
+	assert.Equal(t, http.StatusOK, StatusCode(nil))
+}
+
+func Test_That_StatusCode_Returns_ClientClosedRequest_When_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, StatusClientClosedRequest, StatusCode(improc.CheckContext(ctx)))
+}
+
+func Test_That_StatusCode_Returns_RequestTimeout_When_Deadline_Is_Exceeded(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	assert.Equal(t, http.StatusRequestTimeout, StatusCode(improc.CheckContext(ctx)))
+}
+
+func Test_That_StatusCode_Returns_RequestEntityTooLarge_When_Input_Exceeds_Limits(t *testing.T) {
+	err := fmt.Errorf("%w: the image width exceeds 100 pixels", improc.ErrInputTooLarge)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, StatusCode(err))
+}
+
+func Test_That_StatusCode_Returns_ServiceUnavailable_When_Overloaded(t *testing.T) {
+	assert.Equal(t, http.StatusServiceUnavailable, StatusCode(improc.ErrOverloaded))
+}
+
+func Test_That_StatusCode_Returns_BadGateway_When_Fetch_Fails(t *testing.T) {
+	err := &fetchError{cause: errors.New("unsuccessful request")}
+
+	assert.Equal(t, http.StatusBadGateway, StatusCode(err))
+}
+
+func Test_That_StatusCode_Returns_BadGateway_When_Fetch_Times_Out(t *testing.T) {
+	err := &fetchError{cause: &url.Error{Op: "Get", URL: "https://www.test.com/", Err: context.DeadlineExceeded}}
+
+	assert.Equal(t, http.StatusBadGateway, StatusCode(err))
+}
+
+func Test_That_StatusCode_Returns_BadRequest_For_Other_Errors(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, StatusCode(errors.New("invalid input")))
+}
@@ -2,13 +2,21 @@ package httpimproc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"time"
+
+	improc "github.com/ourstudio-se/go-image-processor/v2"
 )
 
+// ErrFetchFailed is returned when the source image
+// can't be fetched from its URL
+var ErrFetchFailed = errors.New("fetching the source image failed")
+
 type httpclient interface {
 	Do(*http.Request) (*http.Response, error)
 }
@@ -30,32 +38,104 @@ func NewURLReader(source *url.URL) *URLReader {
 	}
 }
 
-// ReadBlob requests the specified URL and returns the result
-// as a byte array
-func (u *URLReader) ReadBlob() ([]byte, error) {
+// Open requests the specified URL and returns the response
+// body as a stream, which must be closed by the caller. The
+// request is aborted as soon as ctx is done
+func (u *URLReader) Open(ctx context.Context) (io.ReadCloser, error) {
 	request, err := http.NewRequest("GET", u.sourceURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.TODO(), u.Timeout)
-	defer cancel()
+	reqCtx, cancel := context.WithTimeout(ctx, u.Timeout)
 
-	resp, err := u.client.Do(request.WithContext(ctx))
+	resp, err := u.client.Do(request.WithContext(reqCtx))
 	if err != nil {
-		return nil, err
-	}
+		cancel()
+		if ctxErr := improc.CheckContext(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
 
-	defer resp.Body.Close()
+		return nil, &fetchError{cause: err}
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unsuccessful request for URL '%s'", u.sourceURL.String())
+		resp.Body.Close()
+		cancel()
+		return nil, &fetchError{cause: fmt.Errorf("unsuccessful request for URL '%s'", u.sourceURL.String())}
+	}
+
+	return &cancelOnClose{
+		ReadCloser: resp.Body,
+		cancel:     cancel,
+	}, nil
+}
+
+// ReadBlob requests the specified URL and returns the result
+// as a byte array
+func (u *URLReader) ReadBlob() ([]byte, error) {
+	return u.ReadBlobContext(context.Background())
+}
+
+// ReadBlobContext works like ReadBlob, but aborts the
+// request as soon as ctx is done
+func (u *URLReader) ReadBlobContext(ctx context.Context) ([]byte, error) {
+	body, err := u.Open(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(body)
 	if err != nil {
+		if ctxErr := improc.CheckContext(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+
 		return nil, err
 	}
 
-	return body, nil
+	return b, nil
+}
+
+// cancelOnClose keeps the request context alive until
+// the response body has been consumed and closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		return n, &fetchError{cause: err}
+	}
+
+	return n, err
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+
+	return err
+}
+
+// fetchError matches ErrFetchFailed while keeping
+// the message of the underlying error
+type fetchError struct {
+	cause error
+}
+
+func (e *fetchError) Error() string {
+	return e.cause.Error()
+}
+
+func (e *fetchError) Is(target error) bool {
+	return target == ErrFetchFailed
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.cause
 }
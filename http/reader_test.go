@@ -2,12 +2,15 @@ package httpimproc
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"testing"
 
+	improc "github.com/ourstudio-se/go-image-processor/v2"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -96,3 +99,75 @@ func Test_That_ReadBlob_Returns_Bytes_From_Response_Body(t *testing.T) {
 
 	assert.Equal(t, string(body), string(resp))
 }
+
+func Test_That_Open_Returns_Response_Body_Stream(t *testing.T) {
+	body := []byte("response body")
+	mh := &mockhttp{
+		returnResponse: &http.Response{
+			Body:       ioutil.NopCloser(bytes.NewBuffer(body)),
+			StatusCode: 200,
+		},
+	}
+	source, _ := url.Parse("https://www.test.com/path")
+	reader := &URLReader{
+		client:    mh,
+		sourceURL: source,
+	}
+
+	stream, _ := reader.Open(context.Background())
+	defer stream.Close()
+	resp, _ := ioutil.ReadAll(stream)
+
+	assert.Equal(t, string(body), string(resp))
+}
+
+func Test_That_ReadBlobContext_Returns_ErrCanceled_When_Context_Is_Done(t *testing.T) {
+	mh := &mockhttp{
+		returnError: errors.New("context canceled"),
+	}
+	source, _ := url.Parse("https://www.test.com/path")
+	reader := &URLReader{
+		client:    mh,
+		sourceURL: source,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := reader.ReadBlobContext(ctx)
+
+	assert.True(t, errors.Is(err, improc.ErrCanceled))
+}
+
+func Test_That_Open_Returns_ErrFetchFailed_On_Unsuccessful_Response(t *testing.T) {
+	mh := &mockhttp{
+		returnResponse: &http.Response{
+			Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+			StatusCode: 404,
+		},
+	}
+	source, _ := url.Parse("https://www.test.com/path")
+	reader := &URLReader{
+		client:    mh,
+		sourceURL: source,
+	}
+
+	_, err := reader.Open(context.Background())
+
+	assert.True(t, errors.Is(err, ErrFetchFailed))
+}
+
+func Test_That_Open_Returns_ErrFetchFailed_On_Transport_Error(t *testing.T) {
+	mh := &mockhttp{
+		returnError: errors.New("connection refused"),
+	}
+	source, _ := url.Parse("https://www.test.com/path")
+	reader := &URLReader{
+		client:    mh,
+		sourceURL: source,
+	}
+
+	_, err := reader.Open(context.Background())
+
+	assert.True(t, errors.Is(err, ErrFetchFailed))
+}
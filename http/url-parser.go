@@ -2,6 +2,7 @@ package httpimproc
 
 import (
 	"fmt"
+	"math"
 	"net/url"
 	"strconv"
 	"strings"
@@ -29,13 +30,91 @@ func ParseURL(u *url.URL, parameters *ParameterMap) (*ProcessingRequest, error)
 		return nil, err
 	}
 
+	if anchor := getFocalAnchor(query, parameters); anchor != nil {
+		formatSpec.Anchor = anchor
+	}
 	if getParam(query, parameters.Crop) == "true" {
 		formatSpec.Crop = true
 	}
+	if fit := getParam(query, parameters.Fit); fit != "" {
+		formatSpec.Fit, err = improc.ParseFitMode(fit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if filter := getParam(query, parameters.Filter); filter != "" {
+		formatSpec.Filter, err = improc.ParseFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if getParam(query, parameters.AutoOrient) == "false" {
+		formatSpec.NoAutoOrient = true
+	}
+	if getParam(query, parameters.EmbedProfile) == "true" {
+		formatSpec.EmbedProfile = true
+	}
+	formatSpec.Compression = getCompression(query, parameters.Compression)
+	if formatSpec.Compression == improc.Avif {
+		formatSpec.Quality = improc.DefaultAvifQuality
+	}
 	if q, err := strconv.Atoi(getParam(query, parameters.Quality)); err == nil && q > 0 && q <= 100 {
 		formatSpec.Quality = uint(q)
 	}
-	formatSpec.Compression = getCompression(query, parameters.Compression)
+	formatSpec.Encoder = getEncoderOptions(query, parameters)
+	if maxBytes, err := strconv.Atoi(getParam(query, parameters.MaxBytes)); err == nil && maxBytes > 0 {
+		formatSpec.MaxBytes = maxBytes
+		formatSpec.BestEffort = getParam(query, parameters.BestEffort) == "true"
+	}
+	if score, err := strconv.ParseFloat(getParam(query, parameters.TargetScore), 64); err == nil && score > 0 && score <= 1 {
+		formatSpec.TargetScore = score
+	}
+	formatSpec.Metadata = getMetadataPolicy(query, parameters.Metadata)
+	formatSpec.Upscale = getUpscaleMode(query, parameters.Upscale)
+	if dpr, err := strconv.ParseFloat(strings.TrimSuffix(getParam(query, parameters.DevicePixelRatio), "x"), 64); err == nil && dpr >= 1 {
+		formatSpec.DevicePixelRatio = dpr
+	}
+
+	if rect := getParam(query, parameters.SourceRect); rect != "" {
+		formatSpec.Source, err = improc.ParseRect(rect)
+		if err != nil {
+			return nil, err
+		}
+	} else if hasRawParam(u.RawQuery, parameters.SourceRect) {
+		// url.Query drops pairs that can't be unescaped, such as
+		// a percent rectangle which isn't URL-encoded
+		return nil, fmt.Errorf("malformed %s parameter", parameters.SourceRect)
+	}
+	if trim := getParam(query, parameters.Trim); trim != "" && trim != "false" {
+		formatSpec.Trim, err = improc.ParseTrimSpec(trim)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if packshot := getParam(query, parameters.Packshot); packshot != "" {
+		formatSpec.Packshot, err = improc.ParsePackshotSpec(packshot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rotate := getParam(query, parameters.Rotate); rotate != "" {
+		formatSpec.Rotate, err = strconv.ParseFloat(rotate, 64)
+		if err != nil || math.IsNaN(formatSpec.Rotate) || math.IsInf(formatSpec.Rotate, 0) {
+			return nil, fmt.Errorf("the rotation %s is not valid", rotate)
+		}
+	}
+	if getParam(query, parameters.Flip) == "true" {
+		formatSpec.Flip = true
+	}
+	if getParam(query, parameters.Flop) == "true" {
+		formatSpec.Flop = true
+	}
+	if frames := getParam(query, parameters.Frames); frames != "" {
+		formatSpec.Frames, err = improc.ParseFrameRange(frames)
+		if err != nil {
+			return nil, err
+		}
+	}
 	formatSpec.Background = getBackgroundColor(query, formatSpec.Compression, parameters.Background)
 	formatSpec.Text = getTextBlock(query, parameters)
 
@@ -83,6 +162,13 @@ func getFormatSpec(values url.Values, parameters *ParameterMap) (*improc.OutputS
 	if anchorX == "" && anchorY == "" {
 		return improc.ParseOutputSpec(template)
 	}
+	// A single smart anchor applies to both axes
+	if anchorX == "" && anchorY == "smart" {
+		anchorX = anchorY
+	}
+	if anchorY == "" && anchorX == "smart" {
+		anchorY = anchorX
+	}
 	if anchorX == "" || anchorY == "" {
 		return nil, fmt.Errorf("malformed anchor specification")
 	}
@@ -91,6 +177,23 @@ func getFormatSpec(values url.Values, parameters *ParameterMap) (*improc.OutputS
 	return improc.ParseOutputSpec(template)
 }
 
+func getFocalAnchor(values url.Values, parameters *ParameterMap) *improc.Anchor {
+	x := getParam(values, parameters.FocusX)
+	y := getParam(values, parameters.FocusY)
+
+	if x == "" && y == "" {
+		return nil
+	}
+	if x == "" {
+		x = "0.5"
+	}
+	if y == "" {
+		y = "0.5"
+	}
+
+	return improc.ParseAnchorSpec(fmt.Sprintf("fp:%s,%s", x, y))
+}
+
 func getCompression(values url.Values, param string) improc.Compression {
 	if outFormat := getParam(values, param); outFormat != "" {
 		switch strings.ToLower(outFormat) {
@@ -100,12 +203,89 @@ func getCompression(values url.Values, param string) improc.Compression {
 			return improc.Png
 		case "webp":
 			return improc.WebP
+		case "avif":
+			return improc.Avif
 		}
 	}
 
 	return improc.TransitiveCompression
 }
 
+func getEncoderOptions(values url.Values, parameters *ParameterMap) improc.EncoderOptions {
+	var options improc.EncoderOptions
+
+	options.Jpeg.Progressive = getParam(values, parameters.JpegProgressive) == "true"
+	options.Jpeg.Optimize = getParam(values, parameters.JpegOptimize) == "true"
+	switch strings.Replace(getParam(values, parameters.JpegSubsampling), ":", "", -1) {
+	case "420":
+		options.Jpeg.Subsampling = improc.Subsampling420
+	case "422":
+		options.Jpeg.Subsampling = improc.Subsampling422
+	case "444":
+		options.Jpeg.Subsampling = improc.Subsampling444
+	}
+
+	options.Png.CompressionLevel = getRange(values, parameters.PngLevel, 1, 9)
+	switch strings.ToLower(getParam(values, parameters.PngFilter)) {
+	case "none":
+		options.Png.Filter = improc.PngFilterNone
+	case "sub":
+		options.Png.Filter = improc.PngFilterSub
+	case "up":
+		options.Png.Filter = improc.PngFilterUp
+	case "average":
+		options.Png.Filter = improc.PngFilterAverage
+	case "paeth":
+		options.Png.Filter = improc.PngFilterPaeth
+	case "adaptive":
+		options.Png.Filter = improc.PngFilterAdaptive
+	}
+
+	options.WebP.Lossless = getParam(values, parameters.WebPLossless) == "true"
+	options.WebP.Method = getRange(values, parameters.WebPMethod, 1, 6)
+	options.WebP.NearLossless = getRange(values, parameters.WebPNearLossless, 1, 99)
+	options.WebP.AlphaQuality = getRange(values, parameters.WebPAlphaQuality, 1, 100)
+
+	options.Avif.Speed = getRange(values, parameters.AvifSpeed, 1, 9)
+
+	return options
+}
+
+// getRange returns a numeric parameter if it's within the range
+// from min to max, and zero otherwise
+func getRange(values url.Values, param string, min, max int) uint {
+	v, err := strconv.Atoi(getParam(values, param))
+	if err != nil || v < min || v > max {
+		return 0
+	}
+
+	return uint(v)
+}
+
+func getMetadataPolicy(values url.Values, param string) improc.MetadataPolicy {
+	switch strings.ToLower(getParam(values, param)) {
+	case "icc":
+		return improc.MetadataKeepICC
+	case "rights":
+		return improc.MetadataKeepRights
+	case "all":
+		return improc.MetadataKeepAll
+	}
+
+	return improc.MetadataStripAll
+}
+
+func getUpscaleMode(values url.Values, param string) improc.UpscaleMode {
+	switch strings.ToLower(getParam(values, param)) {
+	case "false", "clamp":
+		return improc.UpscaleClamp
+	case "pad":
+		return improc.UpscalePad
+	}
+
+	return improc.UpscaleAllow
+}
+
 func getTextBlock(values url.Values, parameters *ParameterMap) *improc.TextBlock {
 	tb := &improc.TextBlock{
 		Foreground: improc.Color("#000000"),
@@ -184,6 +364,18 @@ func getColor(c string) (improc.Color, error) {
 	return improc.Color(fmt.Sprintf("#%s", c)), nil
 }
 
+// hasRawParam tells if a parameter is present in a raw
+// querystring, even when it can't be parsed
+func hasRawParam(rawQuery, name string) bool {
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if strings.SplitN(pair, "=", 2)[0] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func getParam(values url.Values, name string) string {
 	v := values[name]
 	if len(v) == 0 {
@@ -13,28 +13,28 @@ import (
 func Test_That_GetImageSource_Returns_QueryString_Param_URL(t *testing.T) {
 	expected := "https://www.test.com/path"
 	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s", url.QueryEscape(expected)))
-	actual, _ := getImageSource(u.Query())
+	actual, _ := getImageSource(u.Query(), DefaultParameterMap().SourceURL)
 
 	assert.Equal(t, expected, actual.String())
 }
 
 func Test_That_GetImageSource_Returns_Error_On_Missing_QueryString_Param_URL(t *testing.T) {
 	u, _ := url.Parse("https://www.test.com/path?")
-	_, err := getImageSource(u.Query())
+	_, err := getImageSource(u.Query(), DefaultParameterMap().SourceURL)
 
 	assert.Error(t, err)
 }
 
 func Test_That_GetImageSource_Returns_Error_On_Non_Absolute_QueryString_Param_URL(t *testing.T) {
 	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s", url.QueryEscape("/not/absolute")))
-	_, err := getImageSource(u.Query())
+	_, err := getImageSource(u.Query(), DefaultParameterMap().SourceURL)
 
 	assert.Error(t, err)
 }
 
 func Test_That_GetImageSource_Returns_Error_On_Non_HTTP_HTTPS_Scheme_For_QueryString_Param_URL(t *testing.T) {
 	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s", url.QueryEscape("ftps://domain")))
-	_, err := getImageSource(u.Query())
+	_, err := getImageSource(u.Query(), DefaultParameterMap().SourceURL)
 
 	assert.Error(t, err)
 }
@@ -46,7 +46,7 @@ func Test_That_GetFormatSpec_Returns_OutputSpec_Matching_QueryString_Param_Spec(
 	ay := 1
 	spec := fmt.Sprintf("%dx%d@%d,%d", w, h, ax, ay)
 	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?spec=%s", spec))
-	r, _ := getFormatSpec(u.Query())
+	r, _ := getFormatSpec(u.Query(), DefaultParameterMap())
 
 	assert.Equal(t, w, int(r.Width))
 	assert.Equal(t, h, int(r.Height))
@@ -57,7 +57,7 @@ func Test_That_GetFormatSpec_Returns_OutputSpec_Matching_QueryString_Param_Spec(
 func Test_That_GetFormatSpec_Returns_OutputSpec_Width_Matching_QueryString_Param_Width(t *testing.T) {
 	w := 100
 	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?width=%d", w))
-	r, _ := getFormatSpec(u.Query())
+	r, _ := getFormatSpec(u.Query(), DefaultParameterMap())
 
 	assert.Equal(t, w, int(r.Width))
 }
@@ -65,14 +65,14 @@ func Test_That_GetFormatSpec_Returns_OutputSpec_Width_Matching_QueryString_Param
 func Test_That_GetFormatSpec_Returns_OutputSpec_Height_Matching_QueryString_Param_Height(t *testing.T) {
 	h := 200
 	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?height=%d", h))
-	r, _ := getFormatSpec(u.Query())
+	r, _ := getFormatSpec(u.Query(), DefaultParameterMap())
 
 	assert.Equal(t, h, int(r.Height))
 }
 
 func Test_That_GetFormatSpec_Returns_Error_When_QueryString_Missing_Dimensions(t *testing.T) {
 	u, _ := url.Parse("https://www.test.com/path")
-	_, err := getFormatSpec(u.Query())
+	_, err := getFormatSpec(u.Query(), DefaultParameterMap())
 
 	assert.Error(t, err)
 }
@@ -81,7 +81,7 @@ func Test_That_GetFormatSpec_Returns_OutputSpec_Anchors_Matching_QueryString_Par
 	ax := -1
 	ay := 1
 	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?width=100&anchorx=%d&anchory=%d", ax, ay))
-	r, _ := getFormatSpec(u.Query())
+	r, _ := getFormatSpec(u.Query(), DefaultParameterMap())
 
 	assert.Equal(t, improc.GravityPull, r.Anchor.Horizontal)
 	assert.Equal(t, improc.GravityPush, r.Anchor.Vertical)
@@ -89,14 +89,14 @@ func Test_That_GetFormatSpec_Returns_OutputSpec_Anchors_Matching_QueryString_Par
 
 func Test_That_GetFormatSpec_Returns_Error_On_Missing_QueryString_Param_AnchorX(t *testing.T) {
 	u, _ := url.Parse("https://www.test.com/path?width=100&anchory=-1")
-	_, err := getFormatSpec(u.Query())
+	_, err := getFormatSpec(u.Query(), DefaultParameterMap())
 
 	assert.Error(t, err)
 }
 
 func Test_That_GetFormatSpec_Returns_Error_On_Missing_QueryString_Param_AnchorY(t *testing.T) {
 	u, _ := url.Parse("https://www.test.com/path?width=100&anchorx=-1")
-	_, err := getFormatSpec(u.Query())
+	_, err := getFormatSpec(u.Query(), DefaultParameterMap())
 
 	assert.Error(t, err)
 }
@@ -113,13 +113,15 @@ func Test_GetCompression(t *testing.T) {
 		{"Png", improc.Png},
 		{"webp", improc.WebP},
 		{"WEBp", improc.WebP},
+		{"avif", improc.Avif},
+		{"AVIF", improc.Avif},
 		{"notfound", improc.TransitiveCompression},
 	}
 
 	for _, tt := range compressions {
 		t.Run(tt.in, func(t *testing.T) {
-			u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?out=%s", tt.in))
-			r := getCompression(u.Query())
+			u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?output=%s", tt.in))
+			r := getCompression(u.Query(), DefaultParameterMap().Compression)
 
 			assert.Equal(t, tt.out, r)
 		})
@@ -142,9 +144,225 @@ func Test_GetBackgroundColor(t *testing.T) {
 	for _, tt := range colors {
 		t.Run(tt.in, func(t *testing.T) {
 			u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?background=%s", tt.in))
-			r := getBackgroundColor(u.Query(), tt.compression)
+			r := getBackgroundColor(u.Query(), tt.compression, DefaultParameterMap().Background)
 
 			assert.Equal(t, tt.out, r)
 		})
 	}
 }
+
+func Test_GetMetadataPolicy(t *testing.T) {
+	policies := []struct {
+		in  string
+		out improc.MetadataPolicy
+	}{
+		{"", improc.MetadataStripAll},
+		{"strip", improc.MetadataStripAll},
+		{"icc", improc.MetadataKeepICC},
+		{"ICC", improc.MetadataKeepICC},
+		{"rights", improc.MetadataKeepRights},
+		{"all", improc.MetadataKeepAll},
+		{"notfound", improc.MetadataStripAll},
+	}
+
+	for _, tt := range policies {
+		t.Run(tt.in, func(t *testing.T) {
+			u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?metadata=%s", tt.in))
+			r := getMetadataPolicy(u.Query(), DefaultParameterMap().Metadata)
+
+			assert.Equal(t, tt.out, r)
+		})
+	}
+}
+
+func Test_That_ParseURL_Defaults_To_Avif_Quality_For_Avif_Output(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&output=avif&avif:speed=8", url.QueryEscape("https://www.test.com/image.png")))
+	r, _ := ParseURL(u, DefaultParameterMap())
+
+	assert.Equal(t, uint(improc.DefaultAvifQuality), r.OutputSpec.Quality)
+	assert.Equal(t, uint(8), r.OutputSpec.Encoder.Avif.Speed)
+}
+
+func Test_That_GetEncoderOptions_Parses_Format_Specific_Params(t *testing.T) {
+	u, _ := url.Parse("https://www.test.com/path?jpeg:progressive=true&jpeg:subsampling=4:4:4&jpeg:optimize=true&png:level=9&png:filter=paeth&webp:lossless=true&webp:method=6&webp:nearlossless=60&webp:alphaquality=80")
+	r := getEncoderOptions(u.Query(), DefaultParameterMap())
+
+	assert.True(t, r.Jpeg.Progressive)
+	assert.Equal(t, improc.Subsampling444, r.Jpeg.Subsampling)
+	assert.True(t, r.Jpeg.Optimize)
+	assert.Equal(t, uint(9), r.Png.CompressionLevel)
+	assert.Equal(t, improc.PngFilterPaeth, r.Png.Filter)
+	assert.True(t, r.WebP.Lossless)
+	assert.Equal(t, uint(6), r.WebP.Method)
+	assert.Equal(t, uint(60), r.WebP.NearLossless)
+	assert.Equal(t, uint(80), r.WebP.AlphaQuality)
+}
+
+func Test_That_GetEncoderOptions_Ignores_Out_Of_Range_Values(t *testing.T) {
+	u, _ := url.Parse("https://www.test.com/path?png:level=10&webp:method=0&avif:speed=k")
+	r := getEncoderOptions(u.Query(), DefaultParameterMap())
+
+	assert.Equal(t, improc.EncoderOptions{}, r)
+}
+
+func Test_That_ParseURL_Parses_MaxBytes(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&maxbytes=20000&besteffort=true", url.QueryEscape("https://www.test.com/image.png")))
+	r, _ := ParseURL(u, DefaultParameterMap())
+
+	assert.Equal(t, 20000, r.OutputSpec.MaxBytes)
+	assert.True(t, r.OutputSpec.BestEffort)
+}
+
+func Test_That_ParseURL_Parses_TargetScore(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&score=0.95", url.QueryEscape("https://www.test.com/image.png")))
+	r, _ := ParseURL(u, DefaultParameterMap())
+
+	assert.Equal(t, 0.95, r.OutputSpec.TargetScore)
+}
+
+func Test_That_ParseURL_Parses_Filter(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&filter=mitchell", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, improc.FilterMitchell, r.OutputSpec.Filter)
+}
+
+func Test_That_ParseURL_Parses_Fit(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&height=100&fit=inside", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, improc.FitInside, r.OutputSpec.Fit)
+}
+
+func Test_GetUpscaleMode(t *testing.T) {
+	tests := map[string]improc.UpscaleMode{
+		"":      improc.UpscaleAllow,
+		"true":  improc.UpscaleAllow,
+		"false": improc.UpscaleClamp,
+		"clamp": improc.UpscaleClamp,
+		"pad":   improc.UpscalePad,
+	}
+
+	for raw, expected := range tests {
+		t.Run(raw, func(t *testing.T) {
+			u, _ := url.Parse("https://www.test.com/path?upscale=" + raw)
+
+			assert.Equal(t, expected, getUpscaleMode(u.Query(), "upscale"))
+		})
+	}
+}
+
+func Test_That_ParseURL_Parses_DevicePixelRatio(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&dpr=2x", url.QueryEscape("https://www.test.com/image.png")))
+	r, _ := ParseURL(u, DefaultParameterMap())
+
+	assert.Equal(t, float64(2), r.OutputSpec.DevicePixelRatio)
+}
+
+func Test_That_ParseURL_Ignores_DevicePixelRatio_Below_One(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&dpr=0.01", url.QueryEscape("https://www.test.com/image.png")))
+	r, _ := ParseURL(u, DefaultParameterMap())
+
+	assert.Equal(t, float64(0), r.OutputSpec.DevicePixelRatio)
+}
+
+func Test_That_GetFormatSpec_Defaults_Missing_Anchor_To_Smart(t *testing.T) {
+	u, _ := url.Parse("https://www.test.com/path?width=100&height=100&anchorx=smart")
+	r, err := getFormatSpec(u.Query(), DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, improc.GravitySmart, r.Anchor.Horizontal)
+	assert.Equal(t, improc.GravitySmart, r.Anchor.Vertical)
+}
+
+func Test_That_ParseURL_Parses_Focal_Point(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&height=100&focusx=0.25", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &improc.Anchor{Horizontal: improc.GravityFocal, Vertical: improc.GravityFocal, FocusX: 0.25, FocusY: 0.5}, r.OutputSpec.Anchor)
+}
+
+func Test_That_GetFormatSpec_Parses_Anchor_Offsets_Decoded_As_Spaces(t *testing.T) {
+	u, _ := url.Parse("https://www.test.com/path?spec=200x100@1+16,1+16")
+	r, err := getFormatSpec(u.Query(), DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, improc.Offset{Value: 16}, r.Anchor.OffsetX)
+	assert.Equal(t, improc.Offset{Value: 16}, r.Anchor.OffsetY)
+}
+
+func Test_That_ParseURL_Parses_Source_Rect(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&rect=10,20,300,200", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &improc.Rect{X: 10, Y: 20, Width: 300, Height: 200}, r.OutputSpec.Source)
+}
+
+func Test_That_ParseURL_Parses_Relative_Source_Rect(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&rect=%s", url.QueryEscape("https://www.test.com/image.png"), url.QueryEscape("25%,0%,50%,100%")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &improc.Rect{X: 0.25, Y: 0, Width: 0.5, Height: 1, Relative: true}, r.OutputSpec.Source)
+}
+
+func Test_That_ParseURL_Parses_Fractional_Source_Rect(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&rect=0.25,0,0.5,1", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &improc.Rect{X: 0.25, Y: 0, Width: 0.5, Height: 1, Relative: true}, r.OutputSpec.Source)
+}
+
+func Test_That_ParseURL_Returns_Error_For_Unescaped_Percent_Source_Rect(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&rect=25%%,0%%,50%%,100%%", url.QueryEscape("https://www.test.com/image.png")))
+	_, err := ParseURL(u, DefaultParameterMap())
+
+	assert.Error(t, err)
+}
+
+func Test_That_ParseURL_Returns_Error_For_Empty_Source_Rect(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&rect=", url.QueryEscape("https://www.test.com/image.png")))
+	_, err := ParseURL(u, DefaultParameterMap())
+
+	assert.Error(t, err)
+}
+
+func Test_That_ParseURL_Parses_Transforms(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&rotate=12.5&flip=true&flop=true", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, r.OutputSpec.Rotate)
+	assert.True(t, r.OutputSpec.Flip)
+	assert.True(t, r.OutputSpec.Flop)
+}
+
+func Test_That_ParseURL_Returns_Error_For_Non_Finite_Rotation(t *testing.T) {
+	for _, rotate := range []string{"NaN", "Inf", "-Inf"} {
+		u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&rotate=%s", url.QueryEscape("https://www.test.com/image.png"), rotate))
+		_, err := ParseURL(u, DefaultParameterMap())
+
+		assert.Error(t, err, rotate)
+	}
+}
+
+func Test_That_ParseURL_Parses_Trim(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=100&trim=10,16", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &improc.TrimSpec{Fuzz: 10, Margin: 16}, r.OutputSpec.Trim)
+}
+
+func Test_That_ParseURL_Parses_Packshot(t *testing.T) {
+	u, _ := url.Parse(fmt.Sprintf("https://www.test.com/path?url=%s&width=800&packshot=80", url.QueryEscape("https://www.test.com/image.png")))
+	r, err := ParseURL(u, DefaultParameterMap())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &improc.PackshotSpec{Fill: 80, Fuzz: improc.DefaultPackshotFuzz}, r.OutputSpec.Packshot)
+}
@@ -0,0 +1,269 @@
+package improc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// srgbColorants are the sRGB primaries, chromatically
+// adapted to the D50 illuminant
+var srgbColorants = [3][3]float64{
+	{0.4360747, 0.2225045, 0.0139322},
+	{0.3850649, 0.7168786, 0.0971045},
+	{0.1430804, 0.0606169, 0.7141733},
+}
+
+// srgbProfile is a generated ICC v4 display profile for the
+// sRGB color space, used as the default target profile
+var srgbProfile = newSRGBProfile()
+
+// SRGBProfile returns an ICC profile describing the sRGB color
+// space, the default target profile for color conversions
+func SRGBProfile() []byte {
+	return append([]byte{}, srgbProfile...)
+}
+
+type iccTag struct {
+	signature string
+	data      []byte
+}
+
+func newSRGBProfile() []byte {
+	// The sRGB transfer function, as a parametric curve
+	trc := iccParametricCurve(2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045)
+
+	return iccProfile([]iccTag{
+		{"desc", iccMultiLocalized("sRGB")},
+		{"cprt", iccMultiLocalized("No copyright, use freely")},
+		{"wtpt", iccXYZ(0.9642, 1.0, 0.8249)},
+		{"rXYZ", iccXYZ(srgbColorants[0][0], srgbColorants[0][1], srgbColorants[0][2])},
+		{"gXYZ", iccXYZ(srgbColorants[1][0], srgbColorants[1][1], srgbColorants[1][2])},
+		{"bXYZ", iccXYZ(srgbColorants[2][0], srgbColorants[2][1], srgbColorants[2][2])},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+		// The Bradford adaptation from the D65 to the D50 illuminant
+		{"chad", iccMatrix(
+			1.0478112, 0.0228866, -0.0501270,
+			0.0295424, 0.9904844, -0.0170491,
+			-0.0092345, 0.0150436, 0.7521316,
+		)},
+	})
+}
+
+// iccProfile assembles an RGB display profile from its tags
+func iccProfile(tags []iccTag) []byte {
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[8:], 0x04200000)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	binary.BigEndian.PutUint16(header[24:], 2020)
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	copy(header[68:], iccXYZ(0.9642, 1.0, 0.8249)[8:])
+
+	table := make([]byte, 4+len(tags)*12)
+	binary.BigEndian.PutUint32(table, uint32(len(tags)))
+
+	profile := append(header, table...)
+	offsets := make(map[*byte]int)
+	for n, tag := range tags {
+		entry := 128 + 4 + n*12
+		copy(profile[entry:], tag.signature)
+
+		// Identical tag data, such as the TRC curves, is only stored once
+		offset, ok := offsets[&tag.data[0]]
+		if !ok {
+			offset = len(profile)
+			offsets[&tag.data[0]] = offset
+			profile = append(profile, tag.data...)
+			for len(profile)%4 != 0 {
+				profile = append(profile, 0)
+			}
+		}
+
+		binary.BigEndian.PutUint32(profile[entry+4:], uint32(offset))
+		binary.BigEndian.PutUint32(profile[entry+8:], uint32(len(tag.data)))
+	}
+
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+
+	return profile
+}
+
+func iccFixed(v float64) uint32 {
+	return uint32(int32(math.Round(v * 65536)))
+}
+
+func iccXYZ(x, y, z float64) []byte {
+	data := make([]byte, 20)
+	copy(data, "XYZ ")
+	binary.BigEndian.PutUint32(data[8:], iccFixed(x))
+	binary.BigEndian.PutUint32(data[12:], iccFixed(y))
+	binary.BigEndian.PutUint32(data[16:], iccFixed(z))
+
+	return data
+}
+
+func iccMatrix(values ...float64) []byte {
+	data := make([]byte, 8+len(values)*4)
+	copy(data, "sf32")
+	for n, v := range values {
+		binary.BigEndian.PutUint32(data[8+n*4:], iccFixed(v))
+	}
+
+	return data
+}
+
+// iccParametricCurve returns the curve Y = (aX+b)^g for X >= d,
+// and Y = cX for X < d
+func iccParametricCurve(g, a, b, c, d float64) []byte {
+	data := make([]byte, 12, 32)
+	copy(data, "para")
+	binary.BigEndian.PutUint16(data[8:], 3)
+
+	for _, v := range []float64{g, a, b, c, d} {
+		data = append(data, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(data[len(data)-4:], iccFixed(v))
+	}
+
+	return data
+}
+
+func iccMultiLocalized(text string) []byte {
+	encoded := utf16.Encode([]rune(text))
+
+	data := make([]byte, 28, 28+len(encoded)*2)
+	copy(data, "mluc")
+	binary.BigEndian.PutUint32(data[8:], 1)
+	binary.BigEndian.PutUint32(data[12:], 12)
+	copy(data[16:], "enUS")
+	binary.BigEndian.PutUint32(data[20:], uint32(len(encoded)*2))
+	binary.BigEndian.PutUint32(data[24:], 28)
+
+	for _, r := range encoded {
+		data = append(data, byte(r>>8), byte(r))
+	}
+
+	return data
+}
+
+// iccTagData returns the data of a tag in an ICC
+// profile, or nil when the tag can't be found
+func iccTagData(profile []byte, signature string) []byte {
+	if len(profile) < 132 {
+		return nil
+	}
+
+	count := int(binary.BigEndian.Uint32(profile[128:]))
+	for n := 0; n < count; n++ {
+		entry := 132 + n*12
+		if entry+12 > len(profile) {
+			return nil
+		}
+		if string(profile[entry:entry+4]) != signature {
+			continue
+		}
+
+		offset := int64(binary.BigEndian.Uint32(profile[entry+4:]))
+		size := int64(binary.BigEndian.Uint32(profile[entry+8:]))
+		if offset+size > int64(len(profile)) || size < 8 {
+			return nil
+		}
+
+		return profile[offset : offset+size]
+	}
+
+	return nil
+}
+
+// iccDescriptionText reads the text of a v2 description
+// tag, or the first record of a v4 localized one
+func iccDescriptionText(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "desc":
+		end := 12 + int64(binary.BigEndian.Uint32(data[8:]))
+		if end > int64(len(data)) {
+			return ""
+		}
+
+		return strings.TrimRight(string(data[12:end]), "\x00")
+	case len(data) >= 28 && string(data[:4]) == "mluc":
+		if binary.BigEndian.Uint32(data[8:]) == 0 {
+			return ""
+		}
+
+		length := int64(binary.BigEndian.Uint32(data[20:]))
+		offset := int64(binary.BigEndian.Uint32(data[24:]))
+		if offset+length > int64(len(data)) {
+			return ""
+		}
+
+		encoded := make([]uint16, length/2)
+		for n := range encoded {
+			encoded[n] = binary.BigEndian.Uint16(data[offset+int64(n)*2:])
+		}
+
+		return strings.TrimRight(string(utf16.Decode(encoded)), "\x00")
+	}
+
+	return ""
+}
+
+// sameColorSpace tells if converting between two ICC
+// profiles can be skipped
+func sameColorSpace(source, target []byte) bool {
+	return bytes.Equal(source, target) || (isSRGBProfile(source) && isSRGBProfile(target))
+}
+
+// isLinearCurve tells if a TRC tag is the identity curve
+func isLinearCurve(data []byte) bool {
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "curv":
+		count := binary.BigEndian.Uint32(data[8:])
+		return count == 0 || (count == 1 && len(data) >= 14 && binary.BigEndian.Uint16(data[12:]) == 0x0100)
+	case len(data) >= 16 && string(data[:4]) == "para":
+		return binary.BigEndian.Uint16(data[8:]) == 0 && binary.BigEndian.Uint32(data[12:]) == 0x10000
+	}
+
+	return false
+}
+
+// isSRGBProfile tells if an ICC profile describes the sRGB
+// color space, either by its description or its colorants
+func isSRGBProfile(profile []byte) bool {
+	if len(profile) < 132 || string(profile[16:20]) != "RGB " {
+		return false
+	}
+
+	// Linear sRGB profiles share the sRGB description and colorants
+	if isLinearCurve(iccTagData(profile, "rTRC")) {
+		return false
+	}
+
+	description := strings.ToLower(strings.TrimSpace(iccDescriptionText(iccTagData(profile, "desc"))))
+	if strings.HasPrefix(description, "srgb") {
+		return true
+	}
+
+	for n, signature := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		data := iccTagData(profile, signature)
+		if len(data) < 20 || string(data[:4]) != "XYZ " {
+			return false
+		}
+
+		for c := 0; c < 3; c++ {
+			v := float64(int32(binary.BigEndian.Uint32(data[8+c*4:]))) / 65536
+			if math.Abs(v-srgbColorants[n][c]) > 0.002 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,93 @@
+package improc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_SRGBProfile_Has_Valid_ICC_Header(t *testing.T) {
+	profile := SRGBProfile()
+
+	assert.Equal(t, uint32(len(profile)), binary.BigEndian.Uint32(profile))
+	assert.Equal(t, "acsp", string(profile[36:40]))
+	assert.Equal(t, "RGB ", string(profile[16:20]))
+}
+
+func Test_That_SRGBProfile_Tags_Are_Within_Profile(t *testing.T) {
+	profile := SRGBProfile()
+	count := int(binary.BigEndian.Uint32(profile[128:]))
+
+	assert.Equal(t, 10, count)
+	for n := 0; n < count; n++ {
+		entry := 132 + n*12
+		offset := binary.BigEndian.Uint32(profile[entry+4:])
+		size := binary.BigEndian.Uint32(profile[entry+8:])
+
+		assert.Equal(t, uint32(0), offset%4)
+		assert.True(t, int(offset+size) <= len(profile))
+	}
+}
+
+func Test_That_SRGBProfile_Is_Compact(t *testing.T) {
+	assert.True(t, len(SRGBProfile()) < 1024)
+	assert.Equal(t, "para", string(iccTagData(SRGBProfile(), "rTRC")[:4]))
+}
+
+func testRGBProfile(description string, colorants [3][3]float64, trc []byte) []byte {
+	desc := make([]byte, 12)
+	copy(desc, "desc")
+	binary.BigEndian.PutUint32(desc[8:], uint32(len(description)+1))
+	desc = append(append(desc, description...), 0)
+
+	return iccProfile([]iccTag{
+		{"desc", desc},
+		{"rXYZ", iccXYZ(colorants[0][0], colorants[0][1], colorants[0][2])},
+		{"gXYZ", iccXYZ(colorants[1][0], colorants[1][1], colorants[1][2])},
+		{"bXYZ", iccXYZ(colorants[2][0], colorants[2][1], colorants[2][2])},
+		{"rTRC", trc},
+	})
+}
+
+var (
+	testAdobeRGBColorants = [3][3]float64{
+		{0.6097559, 0.3111242, 0.0194811},
+		{0.2052401, 0.6256560, 0.0608902},
+		{0.1492240, 0.0632197, 0.7448387},
+	}
+	testGammaCurve  = []byte{'c', 'u', 'r', 'v', 0, 0, 0, 0, 0, 0, 0, 1, 0x02, 0x33}
+	testLinearCurve = []byte{'c', 'u', 'r', 'v', 0, 0, 0, 0, 0, 0, 0, 0}
+)
+
+func Test_That_IsSRGBProfile_Identifies_Generated_Profile(t *testing.T) {
+	assert.True(t, isSRGBProfile(SRGBProfile()))
+}
+
+func Test_That_IsSRGBProfile_Identifies_Profile_By_Description(t *testing.T) {
+	profile := testRGBProfile("sRGB IEC61966-2.1", testAdobeRGBColorants, testGammaCurve)
+
+	assert.True(t, isSRGBProfile(profile))
+}
+
+func Test_That_IsSRGBProfile_Identifies_Profile_By_Colorants(t *testing.T) {
+	profile := testRGBProfile("Custom", srgbColorants, testGammaCurve)
+
+	assert.True(t, isSRGBProfile(profile))
+}
+
+func Test_That_IsSRGBProfile_Rejects_Other_Colorants(t *testing.T) {
+	profile := testRGBProfile("Adobe RGB (1998)", testAdobeRGBColorants, testGammaCurve)
+
+	assert.False(t, isSRGBProfile(profile))
+}
+
+func Test_That_IsSRGBProfile_Rejects_Linear_sRGB(t *testing.T) {
+	profile := testRGBProfile("sRGB linear", srgbColorants, testLinearCurve)
+
+	assert.False(t, isSRGBProfile(profile))
+}
+
+func Test_That_IsSRGBProfile_Rejects_Invalid_Profile(t *testing.T) {
+	assert.False(t, isSRGBProfile([]byte("not a profile")))
+}
@@ -1,48 +1,193 @@
 package improc
 
 import (
+	"context"
+	"fmt"
+	"image"
+	"io"
 	"math"
-
-	"gopkg.in/gographics/imagick.v3/imagick"
+	"strings"
 )
 
 type handler struct {
-	wand *imagick.MagickWand
+	ctx     context.Context
+	backend Backend
+	img     Image
 }
 
-func newHandler() *handler {
+func newHandler(ctx context.Context, backend Backend) *handler {
 	return &handler{
-		wand: imagick.NewMagickWand(),
+		ctx:     ctx,
+		backend: backend,
+		img:     backend.NewImage(),
 	}
 }
 
 func (h *handler) fromBlob(blob []byte) error {
-	err := h.wand.ReadImageBlob(blob)
+	err := h.img.Read(blob)
 	if err != nil {
 		return err
 	}
 
-	h.wand.SetIteratorIndex(0)
+	return CheckContext(h.ctx)
+}
 
-	return nil
+// selectFrames keeps the frames selected by the spec, or all
+// frames by default. Only the first selected frame is kept when
+// the output compression doesn't support multiple frames
+func (h *handler) selectFrames(spec *OutputSpec) error {
+	frames := h.img.Frames()
+	first, last := uint(0), frames-1
+
+	if spec.Frames != nil && !spec.Frames.All {
+		first, last = spec.Frames.First, spec.Frames.Last
+		if last >= frames {
+			return fmt.Errorf("the frame %d is out of range, the image has %d frames", last, frames)
+		}
+	}
+
+	if !h.isMultiFrame(spec.Compression) {
+		last = first
+	}
+
+	// Frames are selected even when all are kept,
+	// so that animation frames get coalesced
+	return h.img.SelectFrames(first, last)
+}
+
+// isMultiFrame tells if the output can hold multiple frames,
+// such as animations or multi-page documents
+func (h *handler) isMultiFrame(compression Compression) bool {
+	switch compression {
+	case WebP:
+		return true
+	case TransitiveCompression:
+		switch strings.ToUpper(h.img.Format()) {
+		case "GIF", "WEBP", "TIFF", "PDF":
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *handler) autoOrient() error {
+	return h.img.AutoOrient()
+}
+
+// extractSource crops the source region of the spec, which
+// must lie within the image
+func (h *handler) extractSource(rect *Rect) error {
+	width, height := h.img.Width(), h.img.Height()
+	x, y, cropWidth, cropHeight := rect.pixels(width, height)
+
+	if cropWidth == 0 || cropHeight == 0 || uint(x)+cropWidth > width || uint(y)+cropHeight > height {
+		return fmt.Errorf("the source region %dx%d+%d+%d is outside of the %dx%d image", cropWidth, cropHeight, x, y, width, height)
+	}
+
+	return h.img.Crop(cropWidth, cropHeight, x, y)
+}
+
+// trim crops the uniform borders of the image, keeping the margin
+// of the spec as far as the image allows. The borders are found
+// in the first frame, and cropped from every frame
+func (h *handler) trim(spec *TrimSpec) error {
+	bounds := image.Rect(0, 0, int(h.img.Width()), int(h.img.Height()))
+
+	box, err := h.img.TrimBox(spec.Fuzz)
+	if err != nil {
+		return err
+	}
+
+	box = box.Inset(-int(spec.Margin)).Intersect(bounds)
+	if box == bounds {
+		return nil
+	}
+
+	return h.img.Crop(uint(box.Dx()), uint(box.Dy()), box.Min.X, box.Min.Y)
+}
+
+// transform rotates and mirrors the image
+func (h *handler) transform(spec *OutputSpec) error {
+	if !isFinite(spec.Rotate) {
+		return fmt.Errorf("the rotation %v is not valid", spec.Rotate)
+	}
+
+	if math.Mod(spec.Rotate, 360) != 0 {
+		if err := h.img.Rotate(spec.Rotate, spec.Background); err != nil {
+			return err
+		}
+	}
+
+	if spec.Flip {
+		if err := h.img.Flip(); err != nil {
+			return err
+		}
+	}
+
+	if spec.Flop {
+		if err := h.img.Flop(); err != nil {
+			return err
+		}
+	}
+
+	return CheckContext(h.ctx)
+}
+
+func (h *handler) convertProfile(spec *OutputSpec, cmykDefault []byte) error {
+	return h.img.ConvertProfile(spec.targetProfile(), cmykDefault)
+}
+
+func (h *handler) embedProfile(spec *OutputSpec) error {
+	return h.img.SetProfile("icc", spec.targetProfile())
 }
 
 func (h *handler) applyFormat(spec *OutputSpec) error {
 	var err error
 
-	inputWidth := float64(h.wand.GetImageWidth())
-	inputHeight := float64(h.wand.GetImageHeight())
+	if err = CheckContext(h.ctx); err != nil {
+		return err
+	}
+
+	inputWidth := float64(h.img.Width())
+	inputHeight := float64(h.img.Height())
+
+	if spec.Upscale != UpscaleAllow {
+		scaleX, scaleY := fitScale(inputWidth, inputHeight, spec)
+		if scaleX > 1 || scaleY > 1 {
+			if spec.Upscale == UpscalePad {
+				if err = h.applyFormatUnscaled(inputWidth, inputHeight, scaleX, scaleY, spec); err != nil {
+					return err
+				}
+
+				return CheckContext(h.ctx)
+			}
+
+			clamped := *spec
+			clamped.Width = math.Round(spec.Width / math.Max(scaleX, 1))
+			clamped.Height = math.Round(spec.Height / math.Max(scaleY, 1))
+			spec = &clamped
+		}
+	}
+
 	keepsRatio := (spec.Width / spec.Height) == (inputWidth / inputHeight)
 
 	if spec.Width > 0 && spec.Height > 0 && !keepsRatio {
-		if spec.Crop {
-			if err = h.applyFormatWithCrop(inputWidth, inputHeight, spec); err != nil {
-				return err
-			}
-		} else {
-			if err = h.applyFormatWithoutCrop(inputWidth, inputHeight, spec); err != nil {
-				return err
-			}
+		switch spec.fitMode() {
+		case FitCover:
+			err = h.applyFormatWithCrop(inputWidth, inputHeight, spec)
+		case FitFill:
+			err = h.img.Resize(uint(spec.Width), uint(spec.Height), spec.Filter)
+		case FitInside:
+			err = h.applyFormatScaled(inputWidth, inputHeight, math.Min(spec.Width/inputWidth, spec.Height/inputHeight), spec)
+		case FitOutside:
+			err = h.applyFormatScaled(inputWidth, inputHeight, math.Max(spec.Width/inputWidth, spec.Height/inputHeight), spec)
+		default:
+			err = h.applyFormatWithoutCrop(inputWidth, inputHeight, spec)
+		}
+
+		if err != nil {
+			return err
 		}
 	} else {
 		outputWidth := spec.Width
@@ -55,12 +200,108 @@ func (h *handler) applyFormat(spec *OutputSpec) error {
 			outputHeight = math.Ceil((spec.Width / inputWidth) * inputHeight)
 		}
 
-		if err = h.wand.ResizeImage(uint(outputWidth), uint(outputHeight), imagick.FILTER_LANCZOS2); err != nil {
+		if err = h.img.Resize(uint(outputWidth), uint(outputHeight), spec.Filter); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return CheckContext(h.ctx)
+}
+
+// applyPackshot trims the image, scales it to fit within the fill
+// share of the canvas, and centers it on the canvas
+func (h *handler) applyPackshot(spec *OutputSpec) error {
+	canvasWidth, canvasHeight := spec.Width, spec.Height
+	if canvasWidth == 0 {
+		canvasWidth = canvasHeight
+	}
+	if canvasHeight == 0 {
+		canvasHeight = canvasWidth
+	}
+	if canvasWidth < 1 || canvasHeight < 1 {
+		return fmt.Errorf("a packshot needs a width or a height, the spec is %vx%v", spec.Width, spec.Height)
+	}
+
+	if err := h.trim(&TrimSpec{Fuzz: spec.Packshot.Fuzz}); err != nil {
+		return err
+	}
+
+	fill := spec.Packshot.Fill
+	if math.IsNaN(fill) || fill <= 0 || fill > 100 {
+		fill = 100
+	}
+
+	subject := *spec
+	subject.Width = math.Max(1, math.Round(canvasWidth*fill/100))
+	subject.Height = math.Max(1, math.Round(canvasHeight*fill/100))
+	subject.Fit = FitInside
+	subject.Crop = false
+
+	if err := h.applyFormat(&subject); err != nil {
+		return err
+	}
+
+	center := &Anchor{}
+	x := center.GetHorizontalAnchorValue(canvasWidth, float64(h.img.Width()))
+	y := center.GetVerticalAnchorValue(canvasHeight, float64(h.img.Height()))
+
+	return h.img.Extent(uint(canvasWidth), uint(canvasHeight), x, y)
+}
+
+// fitScale returns the horizontal and vertical scale
+// factors the spec applies to an image
+func fitScale(inputWidth, inputHeight float64, spec *OutputSpec) (float64, float64) {
+	scaleX, scaleY := spec.Width/inputWidth, spec.Height/inputHeight
+
+	switch {
+	case spec.Width == 0:
+		return scaleY, scaleY
+	case spec.Height == 0:
+		return scaleX, scaleX
+	}
+
+	switch spec.fitMode() {
+	case FitFill:
+		return scaleX, scaleY
+	case FitCover, FitOutside:
+		scale := math.Max(scaleX, scaleY)
+		return scale, scale
+	}
+
+	scale := math.Min(scaleX, scaleY)
+	return scale, scale
+}
+
+// applyFormatUnscaled places the image on the canvas the spec
+// would produce, without enlarging the image
+func (h *handler) applyFormatUnscaled(inputWidth, inputHeight, scaleX, scaleY float64, spec *OutputSpec) error {
+	canvasWidth, canvasHeight := spec.Width, spec.Height
+	if mode := spec.fitMode(); canvasWidth == 0 || canvasHeight == 0 || mode == FitInside || mode == FitOutside {
+		canvasWidth = math.Round(inputWidth * scaleX)
+		canvasHeight = math.Round(inputHeight * scaleY)
+	}
+
+	nextWidth := math.Round(inputWidth * math.Min(scaleX, 1))
+	nextHeight := math.Round(inputHeight * math.Min(scaleY, 1))
+	if nextWidth != inputWidth || nextHeight != inputHeight {
+		if err := h.img.Resize(uint(nextWidth), uint(nextHeight), spec.Filter); err != nil {
+			return err
+		}
+	}
+
+	x := spec.Anchor.GetHorizontalAnchorValue(canvasWidth, nextWidth)
+	y := spec.Anchor.GetVerticalAnchorValue(canvasHeight, nextHeight)
+
+	return h.img.Extent(uint(canvasWidth), uint(canvasHeight), x, y)
+}
+
+// applyFormatScaled resizes the image by a scale factor,
+// keeping its aspect ratio, without cropping or padding
+func (h *handler) applyFormatScaled(inputWidth, inputHeight, scale float64, spec *OutputSpec) error {
+	outputWidth := math.Max(1, math.Round(inputWidth*scale))
+	outputHeight := math.Max(1, math.Round(inputHeight*scale))
+
+	return h.img.Resize(uint(outputWidth), uint(outputHeight), spec.Filter)
 }
 
 func (h *handler) applyFormatWithoutCrop(inputWidth, inputHeight float64, spec *OutputSpec) error {
@@ -72,25 +313,25 @@ func (h *handler) applyFormatWithoutCrop(inputWidth, inputHeight float64, spec *
 	if isWiderThanHigher {
 		nextWidth := inputWidth * (spec.Height / inputHeight)
 
-		if err = h.wand.ResizeImage(uint(nextWidth), uint(spec.Height), imagick.FILTER_LANCZOS2); err != nil {
+		if err = h.img.Resize(uint(nextWidth), uint(spec.Height), spec.Filter); err != nil {
 			return err
 		}
 
 		anchor := spec.Anchor.GetHorizontalAnchorValue(spec.Width, nextWidth)
 
-		if err = h.wand.ExtentImage(uint(spec.Width), uint(spec.Height), anchor, 0); err != nil {
+		if err = h.img.Extent(uint(spec.Width), uint(spec.Height), anchor, 0); err != nil {
 			return err
 		}
 	} else if isHigherThanWider {
 		nextHeight := inputHeight * (spec.Width / inputWidth)
 
-		if err = h.wand.ResizeImage(uint(spec.Width), uint(nextHeight), imagick.FILTER_LANCZOS2); err != nil {
+		if err = h.img.Resize(uint(spec.Width), uint(nextHeight), spec.Filter); err != nil {
 			return err
 		}
 
 		anchor := spec.Anchor.GetVerticalAnchorValue(spec.Height, nextHeight)
 
-		if err = h.wand.ExtentImage(uint(spec.Width), uint(spec.Height), 0, anchor); err != nil {
+		if err = h.img.Extent(uint(spec.Width), uint(spec.Height), 0, anchor); err != nil {
 			return err
 		}
 	}
@@ -107,25 +348,31 @@ func (h *handler) applyFormatWithCrop(inputWidth, inputHeight float64, spec *Out
 	if isHigherThanWider {
 		nextWidth := inputWidth * (spec.Height / inputHeight)
 
-		if err = h.wand.ResizeImage(uint(nextWidth), uint(spec.Height), imagick.FILTER_LANCZOS2); err != nil {
+		if err = h.img.Resize(uint(nextWidth), uint(spec.Height), spec.Filter); err != nil {
 			return err
 		}
 
-		anchor := spec.Anchor.GetHorizontalAnchorValue(spec.Width, nextWidth)
+		anchor, err := h.cropAnchor(spec.Anchor, spec.Width, nextWidth, true)
+		if err != nil {
+			return err
+		}
 
-		if err = h.wand.CropImage(uint(spec.Width), uint(spec.Height), anchor, 0); err != nil {
+		if err = h.img.Crop(uint(spec.Width), uint(spec.Height), anchor, 0); err != nil {
 			return err
 		}
 	} else if isWiderThanHigher {
 		nextHeight := inputHeight * (spec.Width / inputWidth)
 
-		if err = h.wand.ResizeImage(uint(spec.Width), uint(nextHeight), imagick.FILTER_LANCZOS2); err != nil {
+		if err = h.img.Resize(uint(spec.Width), uint(nextHeight), spec.Filter); err != nil {
 			return err
 		}
 
-		anchor := spec.Anchor.GetVerticalAnchorValue(spec.Height, nextHeight)
+		anchor, err := h.cropAnchor(spec.Anchor, spec.Height, nextHeight, false)
+		if err != nil {
+			return err
+		}
 
-		if err = h.wand.CropImage(uint(spec.Width), uint(spec.Height), 0, anchor); err != nil {
+		if err = h.img.Crop(uint(spec.Width), uint(spec.Height), 0, anchor); err != nil {
 			return err
 		}
 	}
@@ -133,116 +380,281 @@ func (h *handler) applyFormatWithCrop(inputWidth, inputHeight float64, spec *Out
 	return nil
 }
 
-func (h *handler) applyBackground(color Color, compression Compression) {
-	if compression == Jpeg {
-		h.wand.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE)
+// cropAnchor returns the offset of a crop along an axis, where c
+// is the cropped size and n the image size. For GravitySmart the
+// offset is chosen by the content of the image
+func (h *handler) cropAnchor(anchor *Anchor, c, n float64, horizontal bool) (int, error) {
+	g, value := anchor.Vertical, anchor.GetVerticalAnchorValue(c, n)
+	if horizontal {
+		g, value = anchor.Horizontal, anchor.GetHorizontalAnchorValue(c, n)
+	}
 
-		if color == ColorTransparent {
-			color = Color("#FFFFFF")
-		}
+	if g != GravitySmart {
+		// Offsets may not move the crop outside of the image
+		return int(math.Max(0, math.Min(float64(value), n-c))), nil
 	}
-	if compression != Jpeg && compression != TransitiveCompression {
-		h.wand.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET)
+
+	pixels, err := h.img.Pixels()
+	if err != nil {
+		return 0, err
 	}
 
-	bg := imagick.NewPixelWand()
-	defer bg.Destroy()
+	return smartCropOffset(pixels, int(c), horizontal), nil
+}
 
-	bg.SetColor(color.String())
-	h.wand.SetImageBackgroundColor(bg)
+func (h *handler) applyBackground(color Color, compression Compression) error {
+	return h.img.Background(color, compression)
 }
 
 func (h *handler) applyTextBlock(tb *TextBlock) error {
-	var err error
+	if err := CheckContext(h.ctx); err != nil {
+		return err
+	}
 
-	mw := imagick.NewMagickWand()
-	dw := imagick.NewDrawingWand()
-	fg := imagick.NewPixelWand()
-	bg := imagick.NewPixelWand()
+	return h.img.Text(tb)
+}
 
-	defer mw.Destroy()
-	defer dw.Destroy()
-	defer fg.Destroy()
-	defer bg.Destroy()
+func (h *handler) bytes(spec *OutputSpec) ([]byte, *Result, error) {
+	if spec.TargetScore <= 0 {
+		return h.bytesSized(spec)
+	}
 
-	fg.SetColor(tb.Foreground.String())
-	bg.SetColor(tb.Background.String())
+	reference, err := h.img.Pixels()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	dw.SetFillColor(fg)
-	dw.SetFontSize(tb.FontSize)
+	// Lossless output always matches the reference
+	if h.isLossy(spec.Compression) && !(spec.Compression == WebP && spec.Encoder.WebP.Lossless) {
+		quality, err := h.qualityFor(spec, reference)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	if err = dw.SetFont(tb.FontName); err != nil {
-		return err
+		targeted := *spec
+		targeted.Quality = quality
+		spec = &targeted
 	}
 
-	if err = mw.NewImage(h.wand.GetImageWidth(), h.wand.GetImageHeight(), bg); err != nil {
-		return err
+	b, res, err := h.bytesSized(spec)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	fm := mw.QueryFontMetrics(dw, "W")
-	dy := fm.CharacterHeight + fm.Descender
+	res.Score, err = h.score(reference, b)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	dw.Annotation(10, dy, tb.Text)
+	return b, res, nil
+}
 
-	if err = mw.DrawImage(dw); err != nil {
-		return err
+// bytesSized encodes the image, within spec.MaxBytes if it's set
+func (h *handler) bytesSized(spec *OutputSpec) ([]byte, *Result, error) {
+	if spec.MaxBytes <= 0 {
+		return h.encode(spec.Quality, spec.Compression, spec.Encoder)
 	}
-	if err = mw.TrimImage(0); err != nil {
-		return err
+
+	return h.bytesWithin(spec)
+}
+
+// qualityFor searches for the lowest quality, up to the
+// quality of the spec, which reaches spec.TargetScore
+func (h *handler) qualityFor(spec *OutputSpec, reference *image.NRGBA) (uint, error) {
+	quality := spec.Quality
+	if quality == 0 || quality > 100 {
+		quality = 100
 	}
-	if err = mw.SetImageBackgroundColor(bg); err != nil {
-		return err
+
+	// The lowest quality which reaches the score lies in [low, high]
+	low, high := uint(1), quality
+	for low <= high {
+		q := low + (high-low)/2
+
+		b, _, err := h.encode(q, spec.Compression, spec.Encoder)
+		if err != nil {
+			return 0, err
+		}
+
+		score, err := h.score(reference, b)
+		if err != nil {
+			return 0, err
+		}
+
+		if score >= spec.TargetScore {
+			quality = q
+			high = q - 1
+		} else {
+			low = q + 1
+		}
 	}
 
-	nextWidth := int(mw.GetImageWidth()) + int(tb.FontSize)
-	nextHeight := int(mw.GetImageHeight()) + int(dy)
+	return quality, nil
+}
 
-	anchorX := -(nextWidth - int(mw.GetImageWidth())) / 2
-	anchorY := -(nextHeight - int(mw.GetImageHeight()) - int(fm.Descender)) / 2
+// score decodes an encoded blob, and compares it with the
+// reference using SSIM
+func (h *handler) score(reference *image.NRGBA, blob []byte) (float64, error) {
+	decoded := h.backend.NewImage()
+	defer decoded.Destroy()
 
-	if err = mw.ExtentImage(uint(nextWidth), uint(nextHeight), anchorX, anchorY); err != nil {
-		return err
+	if err := decoded.Read(blob); err != nil {
+		return 0, err
+	}
+
+	pixels, err := decoded.Pixels()
+	if err != nil {
+		return 0, err
+	}
+
+	if !pixels.Rect.Size().Eq(reference.Rect.Size()) {
+		return 0, fmt.Errorf("the encoded image is %v, the reference is %v", pixels.Rect.Size(), reference.Rect.Size())
+	}
+
+	return ssim(reference, pixels), nil
+}
+
+func (h *handler) encode(quality uint, compression Compression, options EncoderOptions) ([]byte, *Result, error) {
+	if err := CheckContext(h.ctx); err != nil {
+		return nil, nil, err
 	}
 
-	x, y := 0, 0
-	if tb.Anchor.Horizontal == GravityPull {
-		x = 0
+	b, err := h.img.Encode(quality, compression, &options)
+	if err != nil {
+		return nil, nil, err
 	}
-	if tb.Anchor.Horizontal == GravityCenter {
-		x = int((h.wand.GetImageWidth() / 2) - (mw.GetImageWidth() / 2))
+
+	return b, &Result{
+		Quality:  quality,
+		Lossless: compression == WebP && options.WebP.Lossless,
+		Size:     len(b),
+	}, nil
+}
+
+// bytesWithin searches for the highest quality, up to the
+// quality of the spec, which encodes within spec.MaxBytes.
+// Lossless WebP is tried first and falls back to lossy
+func (h *handler) bytesWithin(spec *OutputSpec) ([]byte, *Result, error) {
+	options := spec.Encoder
+	quality := spec.Quality
+	if quality == 0 || quality > 100 {
+		quality = 100
 	}
-	if tb.Anchor.Horizontal == GravityPush {
-		x = int(h.wand.GetImageWidth() - mw.GetImageWidth())
+
+	if spec.Compression == WebP && options.WebP.Lossless {
+		b, res, err := h.encode(quality, spec.Compression, options)
+		if err != nil || res.Size <= spec.MaxBytes {
+			return b, res, err
+		}
+
+		options.WebP.Lossless = false
 	}
-	if tb.Anchor.Vertical == GravityPull {
-		y = 0
+
+	b, res, err := h.encode(quality, spec.Compression, options)
+	if err != nil || res.Size <= spec.MaxBytes || !h.isLossy(spec.Compression) {
+		return h.withinOrUnreachable(spec, b, res, err)
 	}
-	if tb.Anchor.Vertical == GravityCenter {
-		y = int((h.wand.GetImageHeight() / 2) - (mw.GetImageHeight() / 2))
+
+	// The highest quality which fits lies in [low, high]
+	low, high := uint(1), quality-1
+	best, bestRes := b, res
+
+	for low <= high {
+		q := low + (high-low)/2
+
+		b, res, err := h.encode(q, spec.Compression, options)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if res.Size <= spec.MaxBytes || (bestRes.Size > spec.MaxBytes && res.Size < bestRes.Size) {
+			best, bestRes = b, res
+		}
+
+		if res.Size <= spec.MaxBytes {
+			low = q + 1
+		} else {
+			high = q - 1
+		}
 	}
-	if tb.Anchor.Vertical == GravityPush {
-		y = int(h.wand.GetImageHeight() - mw.GetImageHeight())
+
+	return h.withinOrUnreachable(spec, best, bestRes, nil)
+}
+
+func (h *handler) withinOrUnreachable(spec *OutputSpec, b []byte, res *Result, err error) ([]byte, *Result, error) {
+	if err != nil || res.Size <= spec.MaxBytes || spec.BestEffort {
+		return b, res, err
 	}
 
-	return h.wand.CompositeImage(mw, imagick.COMPOSITE_OP_OVER, true, x, y)
+	return nil, nil, fmt.Errorf("%w: the smallest output is %d bytes, the budget is %d bytes", ErrSizeUnreachable, res.Size, spec.MaxBytes)
 }
 
-func (h *handler) bytes(quality uint, compression Compression) []byte {
-	h.wand.SetImageCompressionQuality(quality)
+// isLossy tells if the output size depends on the quality
+func (h *handler) isLossy(compression Compression) bool {
+	switch compression {
+	case Jpeg, WebP, Avif:
+		return true
+	case TransitiveCompression:
+		switch strings.ToUpper(h.img.Format()) {
+		case "JPEG", "JPG", "WEBP", "AVIF", "HEIC":
+			return true
+		}
+	}
 
-	if compression != TransitiveCompression && compression.String() != "" {
-		h.wand.SetImageFormat(compression.String())
+	return false
+}
+
+func (h *handler) write(w io.Writer, spec *OutputSpec) (*Result, error) {
+	if err := CheckContext(h.ctx); err != nil {
+		return nil, err
+	}
+
+	b, res, err := h.bytes(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckContext(h.ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(b); err != nil {
+		return nil, err
 	}
 
-	h.wand.ResetIterator()
-	b := h.wand.GetImageBlob()
-	return b
+	return res, nil
 }
 
-func (h *handler) strip() {
-	h.wand.StripImage()
+func (h *handler) strip(policy MetadataPolicy) error {
+	if policy == MetadataKeepAll {
+		return nil
+	}
+
+	kept := make(map[string][]byte)
+	for _, name := range policy.profiles() {
+		if profile := h.img.Profile(name); len(profile) > 0 {
+			kept[name] = profile
+		}
+	}
+	for name, filter := range policy.filters() {
+		if profile := filter(h.img.Profile(name)); profile != nil {
+			kept[name] = profile
+		}
+	}
+
+	if err := h.img.Strip(); err != nil {
+		return err
+	}
+
+	for name, profile := range kept {
+		if err := h.img.SetProfile(name, profile); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (h *handler) destroy() {
-	h.wand.Destroy()
+	h.img.Destroy()
 }
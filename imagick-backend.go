@@ -0,0 +1,576 @@
+//go:build !purego
+// +build !purego
+
+package improc
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+var imagickFilters = [...]imagick.FilterType{
+	FilterLanczos2:   imagick.FILTER_LANCZOS2,
+	FilterNearest:    imagick.FILTER_POINT,
+	FilterBox:        imagick.FILTER_BOX,
+	FilterTriangle:   imagick.FILTER_TRIANGLE,
+	FilterHermite:    imagick.FILTER_HERMITE,
+	FilterCatmullRom: imagick.FILTER_CATROM,
+	FilterMitchell:   imagick.FILTER_MITCHELL,
+	FilterLanczos3:   imagick.FILTER_LANCZOS,
+	FilterGaussian:   imagick.FILTER_GAUSSIAN,
+}
+
+type imagickBackend struct {
+	// lcms tells if ImageMagick is built with the LCMS
+	// delegate, which is required to convert color profiles
+	lcms bool
+}
+
+func newBackend() Backend {
+	imagick.Initialize()
+
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	delegates, _ := mw.QueryConfigureOption("DELEGATES")
+
+	b := &imagickBackend{}
+	for _, delegate := range strings.Fields(delegates) {
+		if delegate == "lcms" {
+			b.lcms = true
+		}
+	}
+
+	return b
+}
+
+func (b *imagickBackend) Ping(blob []byte) (*SourceInfo, error) {
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	if err := mw.PingImageBlob(blob); err != nil {
+		return nil, err
+	}
+
+	info := &SourceInfo{
+		Frames: mw.GetNumberImages(),
+	}
+
+	// Frames may be smaller than the canvas they're coalesced
+	// into, and any page of a document may be the largest
+	mw.ResetIterator()
+	for mw.NextImage() {
+		pageWidth, pageHeight, _, _, err := mw.GetImagePage()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, width := range []uint{pageWidth, mw.GetImageWidth()} {
+			if width > info.Width {
+				info.Width = width
+			}
+		}
+		for _, height := range []uint{pageHeight, mw.GetImageHeight()} {
+			if height > info.Height {
+				info.Height = height
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// The width, height and list length resources aren't exposed
+// by the imagick bindings, the values are those of ImageMagick 7
+const (
+	resourceHeight     imagick.ResourceType = 4
+	resourceWidth      imagick.ResourceType = 10
+	resourceListLength imagick.ResourceType = 11
+)
+
+func (b *imagickBackend) SetLimits(limits *InputLimits) error {
+	// Resource limits are global to the ImageMagick environment,
+	// any wand can be used to set them
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	resources := []struct {
+		kind  imagick.ResourceType
+		limit int64
+	}{
+		{resourceWidth, int64(limits.MaxWidth)},
+		{resourceHeight, int64(limits.MaxHeight)},
+		{imagick.RESOURCE_AREA, limits.MaxPixels},
+		{resourceListLength, int64(limits.MaxFrames)},
+	}
+
+	for _, resource := range resources {
+		if resource.limit <= 0 {
+			continue
+		}
+
+		if err := mw.SetResourceLimit(resource.kind, resource.limit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *imagickBackend) NewImage() Image {
+	return &imagickImage{
+		wand: imagick.NewMagickWand(),
+		lcms: b.lcms,
+	}
+}
+
+func (b *imagickBackend) Terminate() {
+	imagick.Terminate()
+}
+
+type imagickImage struct {
+	wand *imagick.MagickWand
+	lcms bool
+}
+
+func (i *imagickImage) Read(blob []byte) error {
+	err := i.wand.ReadImageBlob(blob)
+	if err != nil {
+		return err
+	}
+
+	i.wand.SetIteratorIndex(0)
+
+	return nil
+}
+
+// each calls fn once for every frame of the image, with
+// the iterator of the wand pointing at the frame
+func (i *imagickImage) each(fn func() error) error {
+	defer i.wand.SetIteratorIndex(0)
+
+	i.wand.ResetIterator()
+	for i.wand.NextImage() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *imagickImage) Format() string {
+	return i.wand.GetImageFormat()
+}
+
+func (i *imagickImage) Frames() uint {
+	return i.wand.GetNumberImages()
+}
+
+func (i *imagickImage) SelectFrames(first, last uint) error {
+	frames := i.wand.GetNumberImages()
+	if last >= frames || first > last {
+		return fmt.Errorf("the frames %d-%d are out of range", first, last)
+	}
+
+	// Frames after the selection don't affect the selected
+	// ones, so they are discarded before any coalescing
+	for n := frames - 1; n > last; n-- {
+		i.wand.SetIteratorIndex(int(n))
+		if err := i.wand.RemoveImage(); err != nil {
+			return err
+		}
+	}
+
+	if frames > 1 && i.isAnimation() {
+		// Animation frames may only hold the pixels changed since
+		// the previous frame, coalescing them gives full frames
+		coalesced := i.wand.CoalesceImages()
+		i.wand.Destroy()
+		i.wand = coalesced
+	}
+
+	for n := uint(0); n < first; n++ {
+		i.wand.SetIteratorIndex(0)
+		if err := i.wand.RemoveImage(); err != nil {
+			return err
+		}
+	}
+
+	i.wand.SetIteratorIndex(0)
+
+	return nil
+}
+
+// isAnimation tells if the frames are animation frames,
+// rather than independent pages such as in TIFF or PDF
+func (i *imagickImage) isAnimation() bool {
+	switch strings.ToUpper(i.wand.GetImageFormat()) {
+	case "GIF", "WEBP":
+		return true
+	}
+
+	return false
+}
+
+func (i *imagickImage) Width() uint {
+	return i.wand.GetImageWidth()
+}
+
+func (i *imagickImage) Height() uint {
+	return i.wand.GetImageHeight()
+}
+
+func (i *imagickImage) AutoOrient() error {
+	return i.each(i.wand.AutoOrientImage)
+}
+
+func (i *imagickImage) ConvertProfile(target, cmykDefault []byte) error {
+	return i.each(func() error {
+		return i.convertProfile(target, cmykDefault)
+	})
+}
+
+func (i *imagickImage) convertProfile(target, cmykDefault []byte) error {
+	cmyk := i.wand.GetImageColorspace() == imagick.COLORSPACE_CMYK
+
+	if !i.lcms {
+		// ImageMagick assigns profiles instead of converting without
+		// LCMS, so only a naive conversion into sRGB is possible
+		if !isSRGBProfile(target) {
+			return fmt.Errorf("%w: converting color profiles without LCMS", ErrUnsupported)
+		}
+		if cmyk {
+			return i.wand.TransformImageColorspace(imagick.COLORSPACE_SRGB)
+		}
+
+		return nil
+	}
+
+	embedded := i.Profile("icc")
+	source := embedded
+
+	if len(embedded) == 0 {
+		source = srgbProfile
+
+		if cmyk {
+			if len(cmykDefault) == 0 {
+				// Without any CMYK profile, fall back to a naive conversion
+				if err := i.wand.TransformImageColorspace(imagick.COLORSPACE_SRGB); err != nil {
+					return err
+				}
+			} else {
+				source = cmykDefault
+			}
+		}
+	}
+
+	if sameColorSpace(source, target) {
+		return nil
+	}
+
+	if len(embedded) == 0 {
+		// Profiling an image without an embedded profile assigns it
+		if err := i.wand.ProfileImage("icc", source); err != nil {
+			return err
+		}
+	}
+
+	return i.wand.ProfileImage("icc", target)
+}
+
+func (i *imagickImage) Rotate(degrees float64, background Color) error {
+	if background == "" {
+		background = ColorTransparent
+	}
+
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+
+	bg.SetColor(background.String())
+
+	return i.each(func() error {
+		if err := i.wand.RotateImage(bg, degrees); err != nil {
+			return err
+		}
+
+		// Reset the virtual canvas, which is offset by the rotation
+		return i.wand.SetImagePage(i.wand.GetImageWidth(), i.wand.GetImageHeight(), 0, 0)
+	})
+}
+
+// Flip mirrors horizontally, which ImageMagick calls flop
+func (i *imagickImage) Flip() error {
+	return i.each(i.wand.FlopImage)
+}
+
+// Flop mirrors vertically, which ImageMagick calls flip
+func (i *imagickImage) Flop() error {
+	return i.each(i.wand.FlipImage)
+}
+
+func (i *imagickImage) Resize(width, height uint, filter Filter) error {
+	return i.each(func() error {
+		return i.wand.ResizeImage(width, height, imagickFilters[filter])
+	})
+}
+
+func (i *imagickImage) Extent(width, height uint, x, y int) error {
+	return i.each(func() error {
+		return i.wand.ExtentImage(width, height, x, y)
+	})
+}
+
+func (i *imagickImage) Crop(width, height uint, x, y int) error {
+	return i.each(func() error {
+		if err := i.wand.CropImage(width, height, x, y); err != nil {
+			return err
+		}
+
+		// Reset the virtual canvas, which would otherwise
+		// keep the crop offset for animation frames
+		return i.wand.SetImagePage(i.wand.GetImageWidth(), i.wand.GetImageHeight(), 0, 0)
+	})
+}
+
+func (i *imagickImage) TrimBox(fuzz float64) (image.Rectangle, error) {
+	bounds := image.Rect(0, 0, int(i.wand.GetImageWidth()), int(i.wand.GetImageHeight()))
+
+	frame := i.wand.GetImage()
+	defer frame.Destroy()
+
+	_, quantumRange := imagick.GetQuantumRange()
+	if err := frame.TrimImage(fuzz * float64(quantumRange) / 100); err != nil {
+		return image.Rectangle{}, err
+	}
+
+	// The trimmed region is kept as the offset of the virtual canvas
+	_, _, x, y, err := frame.GetImagePage()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	// Uniform images are trimmed to nothing, with a negative offset
+	if x < 0 || y < 0 {
+		return bounds, nil
+	}
+
+	box := image.Rect(x, y, x+int(frame.GetImageWidth()), y+int(frame.GetImageHeight()))
+	return box.Intersect(bounds), nil
+}
+
+func (i *imagickImage) Background(color Color, compression Compression) error {
+	if compression == Jpeg && color == ColorTransparent {
+		color = Color("#FFFFFF")
+	}
+
+	bg := imagick.NewPixelWand()
+	defer bg.Destroy()
+
+	bg.SetColor(color.String())
+
+	return i.each(func() error {
+		if compression == Jpeg {
+			i.wand.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_REMOVE)
+		}
+		if compression != Jpeg && compression != TransitiveCompression {
+			i.wand.SetImageAlphaChannel(imagick.ALPHA_CHANNEL_SET)
+		}
+
+		return i.wand.SetImageBackgroundColor(bg)
+	})
+}
+
+func (i *imagickImage) Text(tb *TextBlock) error {
+	var err error
+
+	mw := imagick.NewMagickWand()
+	dw := imagick.NewDrawingWand()
+	fg := imagick.NewPixelWand()
+	bg := imagick.NewPixelWand()
+
+	defer mw.Destroy()
+	defer dw.Destroy()
+	defer fg.Destroy()
+	defer bg.Destroy()
+
+	fg.SetColor(tb.Foreground.String())
+	bg.SetColor(tb.Background.String())
+
+	dw.SetFillColor(fg)
+	dw.SetFontSize(tb.FontSize)
+
+	if err = dw.SetFont(tb.FontName); err != nil {
+		return err
+	}
+
+	if err = mw.NewImage(i.wand.GetImageWidth(), i.wand.GetImageHeight(), bg); err != nil {
+		return err
+	}
+
+	fm := mw.QueryFontMetrics(dw, "W")
+	dy := fm.CharacterHeight + fm.Descender
+
+	dw.Annotation(10, dy, tb.Text)
+
+	if err = mw.DrawImage(dw); err != nil {
+		return err
+	}
+	if err = mw.TrimImage(0); err != nil {
+		return err
+	}
+	if err = mw.SetImageBackgroundColor(bg); err != nil {
+		return err
+	}
+
+	nextWidth := int(mw.GetImageWidth()) + int(tb.FontSize)
+	nextHeight := int(mw.GetImageHeight()) + int(dy)
+
+	anchorX := -(nextWidth - int(mw.GetImageWidth())) / 2
+	anchorY := -(nextHeight - int(mw.GetImageHeight()) - int(fm.Descender)) / 2
+
+	if err = mw.ExtentImage(uint(nextWidth), uint(nextHeight), anchorX, anchorY); err != nil {
+		return err
+	}
+
+	// The anchor values are the offsets of the image relative to the text
+	x := -tb.Anchor.GetHorizontalAnchorValue(float64(i.wand.GetImageWidth()), float64(mw.GetImageWidth()))
+	y := -tb.Anchor.GetVerticalAnchorValue(float64(i.wand.GetImageHeight()), float64(mw.GetImageHeight()))
+
+	return i.each(func() error {
+		return i.wand.CompositeImage(mw, imagick.COMPOSITE_OP_OVER, true, x, y)
+	})
+}
+
+func (i *imagickImage) Strip() error {
+	return i.each(i.wand.StripImage)
+}
+
+func (i *imagickImage) Profile(name string) []byte {
+	profile := i.wand.GetImageProfile(name)
+	if profile == "" {
+		return nil
+	}
+
+	return []byte(profile)
+}
+
+func (i *imagickImage) SetProfile(name string, profile []byte) error {
+	return i.each(func() error {
+		return i.wand.SetImageProfile(name, profile)
+	})
+}
+
+func (i *imagickImage) Pixels() (*image.NRGBA, error) {
+	width, height := i.wand.GetImageWidth(), i.wand.GetImageHeight()
+
+	pixels, err := i.wand.ExportImagePixels(0, 0, width, height, "RGBA", imagick.PIXEL_CHAR)
+	if err != nil {
+		return nil, err
+	}
+
+	return &image.NRGBA{
+		Pix:    pixels.([]byte),
+		Stride: int(width) * 4,
+		Rect:   image.Rect(0, 0, int(width), int(height)),
+	}, nil
+}
+
+func (i *imagickImage) Encode(quality uint, compression Compression, options *EncoderOptions) ([]byte, error) {
+	if err := i.setEncoderOptions(compression, options); err != nil {
+		return nil, err
+	}
+
+	err := i.each(func() error {
+		i.wand.SetImageCompressionQuality(quality)
+
+		if compression == Jpeg && options.Jpeg.Progressive {
+			if err := i.wand.SetImageInterlaceScheme(imagick.INTERLACE_JPEG); err != nil {
+				return err
+			}
+		}
+
+		if compression != TransitiveCompression && compression.String() != "" {
+			return i.wand.SetImageFormat(compression.String())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if i.wand.GetNumberImages() == 1 {
+		i.wand.ResetIterator()
+		return i.wand.GetImageBlob(), nil
+	}
+
+	if i.wand.GetImageFormat() == "GIF" {
+		// Coalesced frames are stored in full, optimizing
+		// the layers brings back the minimal frame regions
+		optimized := i.wand.OptimizeImageLayers()
+		defer optimized.Destroy()
+
+		return optimized.GetImagesBlob(), nil
+	}
+
+	i.wand.ResetIterator()
+	return i.wand.GetImagesBlob(), nil
+}
+
+// setEncoderOptions passes the format specific settings
+// to the coders as defines on the wand
+func (i *imagickImage) setEncoderOptions(compression Compression, options *EncoderOptions) error {
+	defines := make(map[string]string)
+
+	switch compression {
+	case Jpeg:
+		if options.Jpeg.Subsampling != SubsamplingDefault {
+			defines["jpeg:sampling-factor"] = options.Jpeg.Subsampling.samplingFactor()
+		}
+		if options.Jpeg.Optimize {
+			defines["jpeg:optimize-coding"] = "true"
+		}
+	case Png:
+		if options.Png.CompressionLevel > 0 {
+			defines["png:compression-level"] = strconv.Itoa(int(options.Png.CompressionLevel))
+		}
+		if options.Png.Filter != PngFilterDefault {
+			// The filter types are numbered from 0 (none) to 5 (adaptive)
+			defines["png:compression-filter"] = strconv.Itoa(int(options.Png.Filter) - 1)
+		}
+	case WebP:
+		// Always set, as the define outlives encodes of the same wand
+		defines["webp:lossless"] = strconv.FormatBool(options.WebP.Lossless)
+		if options.WebP.Method > 0 {
+			defines["webp:method"] = strconv.Itoa(int(options.WebP.Method))
+		}
+		if options.WebP.NearLossless > 0 {
+			defines["webp:near-lossless"] = strconv.Itoa(int(options.WebP.NearLossless))
+		}
+		if options.WebP.AlphaQuality > 0 {
+			defines["webp:alpha-quality"] = strconv.Itoa(int(options.WebP.AlphaQuality))
+		}
+	case Avif:
+		// AVIF is encoded by the HEIC coder through libheif
+		if options.Avif.Speed > 0 {
+			defines["heic:speed"] = strconv.Itoa(int(options.Avif.Speed))
+		}
+	}
+
+	for key, value := range defines {
+		if err := i.wand.SetOption(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *imagickImage) Destroy() {
+	i.wand.Destroy()
+}
@@ -0,0 +1,156 @@
+//go:build !purego
+// +build !purego
+
+package improc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/gographics/imagick.v3/imagick"
+)
+
+func skipWithoutFormat(t *testing.T, format string) {
+	mw := imagick.NewMagickWand()
+	defer mw.Destroy()
+
+	if len(mw.QueryFormats(format)) == 0 {
+		t.Skipf("imagemagick is built without a %s delegate", format)
+	}
+}
+
+func testAnimation(t *testing.T, width, height int, delays ...int) []byte {
+	anim := &gif.GIF{LoopCount: 3}
+	palette := color.Palette{color.Black, color.White}
+
+	for n, delay := range delays {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		for x := 0; x < width; x++ {
+			frame.SetColorIndex(x, n%height, 1)
+		}
+
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func Test_That_Apply_Resizes_All_Frames_Of_Animated_Gif(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = TransitiveCompression
+
+	output, err := converter.Apply(testAnimation(t, 100, 40, 10, 20, 30), spec)
+
+	assert.NoError(t, err)
+	anim, err := gif.DecodeAll(bytes.NewReader(output))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{10, 20, 30}, anim.Delay)
+	assert.Equal(t, 3, anim.LoopCount)
+	assert.Equal(t, 50, anim.Config.Width)
+	assert.Equal(t, 20, anim.Config.Height)
+}
+
+func Test_That_Apply_Converts_Animated_Gif_To_Animated_WebP(t *testing.T) {
+	converter := NewImageConverter()
+	skipWithoutFormat(t, "WEBP")
+
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = WebP
+
+	output, err := converter.Apply(testAnimation(t, 100, 40, 10, 20), spec)
+
+	assert.NoError(t, err)
+	assert.True(t, bytes.Contains(output, []byte("ANIM")))
+}
+
+func Test_That_Apply_Keeps_First_Frame_For_Jpeg_Output(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = Jpeg
+
+	output, err := converter.Apply(testAnimation(t, 100, 40, 10, 20), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 50, w)
+	assert.Equal(t, 20, h)
+}
+
+func Test_That_Apply_Extracts_Selected_Frame(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Compression = TransitiveCompression
+	spec.Frames = &FrameRange{First: 1, Last: 2}
+
+	output, err := converter.Apply(testAnimation(t, 100, 40, 10, 20, 30), spec)
+
+	assert.NoError(t, err)
+	anim, err := gif.DecodeAll(bytes.NewReader(output))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{20, 30}, anim.Delay)
+}
+
+func Test_That_Apply_Coalesces_Selected_Animation_Frame(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 100, 40), palette),
+			image.NewPaletted(image.Rect(10, 10, 20, 20), palette),
+		},
+		Delay:  []int{10, 10},
+		Config: image.Config{ColorModel: palette, Width: 100, Height: 40},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("100x")
+	spec.Compression = Png
+	spec.Frames = &FrameRange{First: 1, Last: 1}
+
+	output, err := converter.Apply(buf.Bytes(), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 40, h)
+}
+
+func Test_That_Apply_Returns_Error_For_Frame_Out_Of_Range(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("50x")
+	spec.Frames = &FrameRange{First: 5, Last: 5}
+
+	_, err := converter.Apply(testAnimation(t, 100, 40, 10, 20), spec)
+
+	assert.Error(t, err)
+}
+
+func Test_That_Apply_Encodes_Avif(t *testing.T) {
+	converter := NewImageConverter()
+	skipWithoutFormat(t, "AVIF")
+
+	spec, _ := ParseOutputSpec("100x")
+	spec.Compression = Avif
+	spec.Quality = DefaultAvifQuality
+	spec.Encoder.Avif.Speed = 9
+
+	output, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.NoError(t, err)
+	assert.True(t, len(output) > 12)
+	assert.True(t, bytes.Equal([]byte("ftypavif"), output[4:12]))
+}
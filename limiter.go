@@ -0,0 +1,98 @@
+package improc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrOverloaded is returned when a conversion can't be started
+// because the converter has reached its concurrency or pixel
+// budget, and the converter is configured to fail fast
+var ErrOverloaded = errors.New("image converter is overloaded")
+
+// Stats holds counters of the work currently
+// handled by an ImageConverter
+type Stats struct {
+	// InFlight is the number of conversions being processed
+	InFlight int64
+
+	// Queued is the number of conversions waiting for
+	// a free slot or for pixel budget
+	Queued int64
+}
+
+// limiter bounds the number of concurrent conversions, and
+// the total number of source pixels decoded at the same time
+type limiter struct {
+	// Counters are kept first, to be 64-bit aligned for atomic access
+	inFlight    int64
+	queued      int64
+	slots       *semaphore.Weighted
+	pixels      *semaphore.Weighted
+	pixelBudget int64
+	failFast    bool
+}
+
+func (l *limiter) acquire(ctx context.Context, pixels int64) (func(), error) {
+	if l.pixels != nil && pixels > l.pixelBudget {
+		return nil, fmt.Errorf("%w: the image has %d pixels, exceeding the budget of %d", ErrOverloaded, pixels, l.pixelBudget)
+	}
+
+	atomic.AddInt64(&l.queued, 1)
+	defer atomic.AddInt64(&l.queued, -1)
+
+	if err := l.take(ctx, l.slots, 1); err != nil {
+		return nil, err
+	}
+	if err := l.take(ctx, l.pixels, pixels); err != nil {
+		if l.slots != nil {
+			l.slots.Release(1)
+		}
+
+		return nil, err
+	}
+
+	atomic.AddInt64(&l.inFlight, 1)
+
+	return func() {
+		atomic.AddInt64(&l.inFlight, -1)
+
+		if l.pixels != nil {
+			l.pixels.Release(pixels)
+		}
+		if l.slots != nil {
+			l.slots.Release(1)
+		}
+	}, nil
+}
+
+func (l *limiter) take(ctx context.Context, sem *semaphore.Weighted, n int64) error {
+	if sem == nil {
+		return nil
+	}
+
+	if l.failFast {
+		if !sem.TryAcquire(n) {
+			return ErrOverloaded
+		}
+
+		return nil
+	}
+
+	if err := sem.Acquire(ctx, n); err != nil {
+		return CheckContext(ctx)
+	}
+
+	return nil
+}
+
+func (l *limiter) stats() Stats {
+	return Stats{
+		InFlight: atomic.LoadInt64(&l.inFlight),
+		Queued:   atomic.LoadInt64(&l.queued),
+	}
+}
@@ -0,0 +1,64 @@
+package improc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/semaphore"
+)
+
+func Test_That_Limiter_Without_Limits_Never_Blocks(t *testing.T) {
+	l := &limiter{}
+
+	release, err := l.acquire(context.Background(), 1000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), l.stats().InFlight)
+	release()
+	assert.Equal(t, int64(0), l.stats().InFlight)
+}
+
+func Test_That_Limiter_Fails_Fast_With_ErrOverloaded_When_Slots_Are_Taken(t *testing.T) {
+	l := &limiter{
+		slots:    semaphore.NewWeighted(1),
+		failFast: true,
+	}
+
+	release, _ := l.acquire(context.Background(), 0)
+	defer release()
+
+	_, err := l.acquire(context.Background(), 0)
+
+	assert.True(t, errors.Is(err, ErrOverloaded))
+}
+
+func Test_That_Limiter_Returns_ErrCanceled_While_Waiting_For_Pixel_Budget(t *testing.T) {
+	l := &limiter{
+		pixels:      semaphore.NewWeighted(100),
+		pixelBudget: 100,
+	}
+
+	release, _ := l.acquire(context.Background(), 80)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := l.acquire(ctx, 80)
+
+	assert.True(t, errors.Is(err, ErrCanceled))
+	assert.Equal(t, int64(0), l.stats().Queued)
+}
+
+func Test_That_Limiter_Returns_ErrOverloaded_For_Images_Larger_Than_Pixel_Budget(t *testing.T) {
+	l := &limiter{
+		pixels:      semaphore.NewWeighted(100),
+		pixelBudget: 100,
+	}
+
+	_, err := l.acquire(context.Background(), 101)
+
+	assert.True(t, errors.Is(err, ErrOverloaded))
+}
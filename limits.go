@@ -0,0 +1,74 @@
+package improc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInputTooLarge is returned when a source image exceeds
+// the input limits configured on the converter
+var ErrInputTooLarge = errors.New("input image is too large")
+
+// InputLimits restricts which source images the converter
+// accepts. Zero values leave the corresponding limit unset
+type InputLimits struct {
+	// MaxWidth is the maximum source width in pixels
+	MaxWidth uint
+
+	// MaxHeight is the maximum source height in pixels
+	MaxHeight uint
+
+	// MaxPixels is the maximum source area in pixels
+	MaxPixels int64
+
+	// MaxFrames is the maximum number of frames or
+	// pages in the source image
+	MaxFrames uint
+
+	// MaxFileSize is the maximum source blob size in bytes
+	MaxFileSize int64
+}
+
+// WithInputLimits makes the converter reject source images
+// exceeding the limits with ErrInputTooLarge. The dimensions
+// are checked from the image header before any pixel data
+// is decoded, and are also set as resource limits of the
+// backend. ImageMagick resource limits are process-wide, so
+// they apply to every converter
+func WithInputLimits(limits InputLimits) Option {
+	return func(c *ImageConverter) {
+		c.limits = &limits
+		if err := c.backend.SetLimits(&limits); err != nil {
+			c.err = fmt.Errorf("setting the input limits: %w", err)
+		}
+	}
+}
+
+func (l *InputLimits) hasDimensions() bool {
+	return l.MaxWidth > 0 || l.MaxHeight > 0 || l.MaxPixels > 0 || l.MaxFrames > 0
+}
+
+func (l *InputLimits) checkFileSize(size int64) error {
+	if l.MaxFileSize > 0 && size > l.MaxFileSize {
+		return fmt.Errorf("%w: the file size exceeds %d bytes", ErrInputTooLarge, l.MaxFileSize)
+	}
+
+	return nil
+}
+
+func (l *InputLimits) check(info *SourceInfo) error {
+	if l.MaxWidth > 0 && info.Width > l.MaxWidth {
+		return fmt.Errorf("%w: the width %d exceeds %d", ErrInputTooLarge, info.Width, l.MaxWidth)
+	}
+	if l.MaxHeight > 0 && info.Height > l.MaxHeight {
+		return fmt.Errorf("%w: the height %d exceeds %d", ErrInputTooLarge, info.Height, l.MaxHeight)
+	}
+	if pixels := int64(info.Width) * int64(info.Height); l.MaxPixels > 0 && pixels > l.MaxPixels {
+		return fmt.Errorf("%w: the area of %d pixels exceeds %d", ErrInputTooLarge, pixels, l.MaxPixels)
+	}
+	if l.MaxFrames > 0 && info.Frames > l.MaxFrames {
+		return fmt.Errorf("%w: the number of frames %d exceeds %d", ErrInputTooLarge, info.Frames, l.MaxFrames)
+	}
+
+	return nil
+}
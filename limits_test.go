@@ -0,0 +1,87 @@
+package improc
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testLimits = &InputLimits{
+	MaxWidth:  1000,
+	MaxHeight: 800,
+	MaxPixels: 500000,
+	MaxFrames: 10,
+}
+
+func Test_That_InputLimits_Accepts_Source_Within_Limits(t *testing.T) {
+	err := testLimits.check(&SourceInfo{Width: 700, Height: 700, Frames: 1})
+
+	assert.NoError(t, err)
+}
+
+func Test_That_InputLimits_Rejects_Source_Exceeding_Width(t *testing.T) {
+	err := testLimits.check(&SourceInfo{Width: 1001, Height: 10, Frames: 1})
+
+	assert.True(t, errors.Is(err, ErrInputTooLarge))
+}
+
+func Test_That_InputLimits_Rejects_Source_Exceeding_Height(t *testing.T) {
+	err := testLimits.check(&SourceInfo{Width: 10, Height: 801, Frames: 1})
+
+	assert.True(t, errors.Is(err, ErrInputTooLarge))
+}
+
+func Test_That_InputLimits_Rejects_Source_Exceeding_Pixels(t *testing.T) {
+	err := testLimits.check(&SourceInfo{Width: 1000, Height: 800, Frames: 1})
+
+	assert.True(t, errors.Is(err, ErrInputTooLarge))
+}
+
+func Test_That_InputLimits_Rejects_Source_Exceeding_Frames(t *testing.T) {
+	err := testLimits.check(&SourceInfo{Width: 10, Height: 10, Frames: 11})
+
+	assert.True(t, errors.Is(err, ErrInputTooLarge))
+}
+
+func Test_That_Apply_Returns_ErrInputTooLarge_For_Large_Header_Dimensions(t *testing.T) {
+	converter := NewImageConverter(WithInputLimits(InputLimits{MaxWidth: 100}))
+	spec, _ := ParseOutputSpec("50x")
+
+	_, err := converter.Apply(testImage(t, 200, 10), spec)
+
+	assert.True(t, errors.Is(err, ErrInputTooLarge))
+}
+
+func Test_That_Apply_Returns_ErrInputTooLarge_For_Large_Files(t *testing.T) {
+	converter := NewImageConverter(WithInputLimits(InputLimits{MaxFileSize: 10}))
+	spec, _ := ParseOutputSpec("50x")
+
+	_, err := converter.Apply(testImage(t, 20, 20), spec)
+
+	assert.True(t, errors.Is(err, ErrInputTooLarge))
+}
+
+func Test_That_Apply_Returns_ErrInputTooLarge_For_Large_Animation_Canvas(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{
+		Image:  []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 10, 10), palette)},
+		Delay:  []int{10},
+		Config: image.Config{ColorModel: palette, Width: 2000, Height: 10},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	converter := NewImageConverter(WithInputLimits(InputLimits{MaxWidth: 100}))
+	spec, _ := ParseOutputSpec("50x")
+
+	_, err := converter.Apply(buf.Bytes(), spec)
+
+	assert.True(t, errors.Is(err, ErrInputTooLarge))
+}
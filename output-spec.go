@@ -2,6 +2,7 @@ package improc
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -19,6 +20,15 @@ const (
 
 	// GravityPush enum value
 	GravityPush Gravity = 2
+
+	// GravitySmart chooses the crop window holding the
+	// most detail. It's handled as GravityCenter when
+	// padding an image or placing text
+	GravitySmart Gravity = 3
+
+	// GravityFocal keeps the focal point of the Anchor as
+	// close to the center of the canvas as possible
+	GravityFocal Gravity = 4
 )
 
 // Compression is an Enum specifying possible
@@ -38,12 +48,135 @@ const (
 	// TransitiveCompression is using the
 	// same compression algorithm as input source
 	TransitiveCompression
+
+	// Avif image compression
+	Avif
 )
 
 func (c Compression) String() string {
-	return [...]string{"jpg", "png", "webp", ""}[c]
+	return [...]string{"jpg", "png", "webp", "", "avif"}[c]
+}
+
+// MetadataPolicy is an Enum specifying which
+// metadata to retain in the output image
+type MetadataPolicy int
+
+const (
+	// MetadataStripAll removes all metadata
+	MetadataStripAll MetadataPolicy = iota
+
+	// MetadataKeepICC keeps the ICC color profile only
+	MetadataKeepICC
+
+	// MetadataKeepRights keeps the ICC color profile, the IPTC
+	// record, the XMP rights and credit properties, and the
+	// EXIF artist and copyright fields
+	MetadataKeepRights
+
+	// MetadataKeepAll keeps all metadata
+	MetadataKeepAll
+)
+
+// profiles returns the names of the profiles to
+// retain as-is when stripping metadata
+func (p MetadataPolicy) profiles() []string {
+	switch p {
+	case MetadataKeepICC:
+		return []string{"icc"}
+	case MetadataKeepRights:
+		return []string{"icc", "iptc"}
+	}
+
+	return nil
+}
+
+// filters returns functions reducing profiles to what's
+// retained when stripping metadata, keyed by profile name
+func (p MetadataPolicy) filters() map[string]func([]byte) []byte {
+	if p != MetadataKeepRights {
+		return nil
+	}
+
+	return map[string]func([]byte) []byte{
+		"exif": exifRights,
+		// IPTC data in JPEG images is held by the 8BIM profile
+		"8bim": photoshopIPTC,
+		"xmp":  xmpRights,
+	}
+}
+
+// FitMode is an Enum specifying how an image is fitted
+// into the output dimensions, when both are given
+type FitMode int
+
+const (
+	// FitContain scales the image to fit within the output
+	// dimensions, and pads the canvas to fill them
+	FitContain FitMode = iota
+
+	// FitCover scales the image to cover the output
+	// dimensions, and crops what's outside of them
+	FitCover
+
+	// FitFill stretches the image to the output
+	// dimensions, ignoring its aspect ratio
+	FitFill
+
+	// FitInside scales the image to fit within the
+	// output dimensions, without padding
+	FitInside
+
+	// FitOutside scales the image to cover the
+	// output dimensions, without cropping
+	FitOutside
+)
+
+var fitModeNames = [...]string{"contain", "cover", "fill", "inside", "outside"}
+
+func (m FitMode) String() string {
+	return fitModeNames[m]
 }
 
+// ParseFitMode returns the FitMode with the given name,
+// such as "cover". The names "pad" and "crop" are
+// accepted for FitContain and FitCover
+func ParseFitMode(raw string) (FitMode, error) {
+	name := strings.ToLower(raw)
+
+	switch name {
+	case "pad":
+		return FitContain, nil
+	case "crop":
+		return FitCover, nil
+	}
+
+	for i, modeName := range fitModeNames {
+		if name == modeName {
+			return FitMode(i), nil
+		}
+	}
+
+	return FitContain, fmt.Errorf("the fit mode %s is not valid", raw)
+}
+
+// UpscaleMode is an Enum specifying how images smaller
+// than the output dimensions are handled
+type UpscaleMode int
+
+const (
+	// UpscaleAllow enlarges the image to the output dimensions
+	UpscaleAllow UpscaleMode = iota
+
+	// UpscaleClamp never enlarges the image, and shrinks the
+	// output dimensions by the scale it would have been
+	// enlarged with, keeping their aspect ratio
+	UpscaleClamp
+
+	// UpscalePad never enlarges the image, and places it
+	// on a canvas of the output dimensions, using the anchor
+	UpscalePad
+)
+
 // Color is a type definition for either a "none" value
 // or for a hex numbered string
 type Color string
@@ -61,21 +194,71 @@ func (c Color) String() string {
 type Anchor struct {
 	Horizontal Gravity
 	Vertical   Gravity
+
+	// FocusX and FocusY is the focal point used by GravityFocal,
+	// as fractions from 0 to 1 of the image width and height
+	FocusX float64
+	FocusY float64
+
+	// OffsetX and OffsetY moves the image away from the edge
+	// it's pulled or pushed to, or from the center
+	OffsetX Offset
+	OffsetY Offset
+}
+
+// Offset is a distance from an anchor point, in pixels
+// or in percent of the canvas size
+type Offset struct {
+	Value   float64
+	Percent bool
+}
+
+// shift returns the offset in pixels, in the direction of
+// the anchor value for the gravity, on a canvas of size c
+func (o Offset) shift(g Gravity, c float64) int {
+	pixels := o.Value
+	if o.Percent {
+		pixels = o.Value * c / 100
+	}
+
+	if g == GravityPush {
+		// Pushed images are moved away from the bottom/right edge
+		return int(math.Round(pixels))
+	}
+
+	return int(math.Round(-pixels))
 }
 
 // GetHorizontalAnchorValue calculates where the anchor point should be relative to the
 // current width (c) and the next/specified width (n) of the image
 func (a *Anchor) GetHorizontalAnchorValue(c, n float64) int {
-	return getAnchorValue(a.Horizontal, c, n)
+	return getAnchorValue(a.Horizontal, a.FocusX, c, n) + a.OffsetX.shift(a.Horizontal, c)
 }
 
 // GetVerticalAnchorValue calculates where the anchor point should be relative to the
 // current height (c) and the next/specified height (n) of the image
 func (a *Anchor) GetVerticalAnchorValue(c, n float64) int {
-	return getAnchorValue(a.Vertical, c, n)
+	return getAnchorValue(a.Vertical, a.FocusY, c, n) + a.OffsetY.shift(a.Vertical, c)
 }
 
-func getAnchorValue(g Gravity, c, n float64) int {
+// scaled returns the anchor with its pixel offsets multiplied by ratio
+func (a *Anchor) scaled(ratio float64) *Anchor {
+	if a == nil {
+		return nil
+	}
+
+	anchor := *a
+	if !anchor.OffsetX.Percent {
+		anchor.OffsetX.Value *= ratio
+	}
+	if !anchor.OffsetY.Percent {
+		anchor.OffsetY.Value *= ratio
+	}
+
+	return &anchor
+}
+
+func getAnchorValue(g Gravity, focus, c, n float64) int {
 	if g == GravityPull {
 		// We "pull" the anchor to the top/left of the canvas
 		return 0
@@ -84,6 +267,11 @@ func getAnchorValue(g Gravity, c, n float64) int {
 		// We "push" the anchor to the bottom/right of the canvas
 		return int(-(c - n))
 	}
+	if g == GravityFocal {
+		// We center the focal point, without moving the
+		// image further than to the edges of the canvas
+		return int(math.Max(math.Min(focus*n-c/2, math.Max(0, n-c)), math.Min(0, n-c)))
+	}
 
 	// Default gravity anchor point is the middle/center of the canvas
 	return int(-(c - n) / 2)
@@ -110,7 +298,344 @@ type OutputSpec struct {
 	Background  Color
 	Quality     uint
 	Compression Compression
+	Encoder     EncoderOptions
 	Text        *TextBlock
+
+	// Fit specifies how the image is fitted into the dimensions,
+	// when both Width and Height are given. Crop is an alias
+	// for FitCover
+	Fit FitMode
+
+	// Filter is the resampling filter used when resizing
+	Filter Filter
+
+	// Upscale specifies if images smaller than the
+	// output dimensions are enlarged
+	Upscale UpscaleMode
+
+	// Source is a region of the source image, which is extracted
+	// before resizing. The region is taken from the oriented image
+	Source *Rect
+
+	// Trim removes uniform borders from the image, after
+	// extracting Source and before rotating
+	Trim *TrimSpec
+
+	// Packshot trims the image and centers it on the canvas,
+	// replacing the fit mode and anchor
+	Packshot *PackshotSpec
+
+	// Rotate rotates the image clockwise by the given degrees,
+	// after trimming and before resizing. Uncovered
+	// corners are filled with Background
+	Rotate float64
+
+	// Flip mirrors the image horizontally, and Flop mirrors
+	// it vertically. Both are applied after Rotate
+	Flip bool
+	Flop bool
+
+	// DevicePixelRatio multiplies the dimensions, the text size
+	// and the pixel offsets, for high density screens. It's limited by the
+	// maximum ratio of the converter, and ratios below 1 are ignored
+	DevicePixelRatio float64
+
+	// NoAutoOrient disables rotating and flipping the
+	// image according to its EXIF orientation
+	NoAutoOrient bool
+
+	// Metadata specifies which metadata to retain,
+	// by default all metadata is stripped
+	Metadata MetadataPolicy
+
+	// Frames selects frames or pages of a multi-frame source.
+	// By default all frames are kept when the output supports
+	// multiple frames, and the first frame is kept otherwise
+	Frames *FrameRange
+
+	// ColorProfile is the ICC profile the image is converted
+	// into before encoding, defaults to sRGB when empty
+	ColorProfile []byte
+
+	// EmbedProfile embeds the target ICC profile in
+	// the output image, regardless of Metadata
+	EmbedProfile bool
+
+	// MaxBytes limits the output size. The highest quality, up
+	// to Quality, which encodes within the limit is used
+	MaxBytes int
+
+	// BestEffort returns the smallest output found instead of
+	// ErrSizeUnreachable, when MaxBytes can't be met
+	BestEffort bool
+
+	// TargetScore is the SSIM score, from 0 to 1, the output must
+	// reach compared with the image before encoding. The lowest
+	// quality, up to Quality, which reaches it is used. MaxBytes
+	// may lower the quality further
+	TargetScore float64
+}
+
+func (s *OutputSpec) fitMode() FitMode {
+	if s.Crop && s.Fit == FitContain {
+		return FitCover
+	}
+
+	return s.Fit
+}
+
+// withDevicePixelRatio returns the spec with its dimensions, text
+// size and pixel offsets multiplied by the device pixel ratio,
+// limited to max. Ratios below 1 never shrink the output
+func (s *OutputSpec) withDevicePixelRatio(max float64) *OutputSpec {
+	ratio := math.Min(s.DevicePixelRatio, max)
+	if math.IsNaN(ratio) || ratio <= 1 {
+		return s
+	}
+
+	scaled := *s
+	scaled.Width = math.Round(s.Width * ratio)
+	scaled.Height = math.Round(s.Height * ratio)
+	scaled.Anchor = s.Anchor.scaled(ratio)
+
+	if s.Text != nil {
+		text := *s.Text
+		text.FontSize *= ratio
+		text.Anchor = s.Text.Anchor.scaled(ratio)
+		scaled.Text = &text
+	}
+
+	return &scaled
+}
+
+func (s *OutputSpec) targetProfile() []byte {
+	if len(s.ColorProfile) == 0 {
+		return srgbProfile
+	}
+
+	return s.ColorProfile
+}
+
+// Rect is a rectangular region of an image, in pixels, or
+// in fractions from 0 to 1 of the image size when Relative
+type Rect struct {
+	X        float64
+	Y        float64
+	Width    float64
+	Height   float64
+	Relative bool
+}
+
+// ParseRect takes a string in the form "x,y,width,height" and
+// returns a Rect. The values are pixels, such as "10,0,300,200",
+// or fractions of the image size when none is above 1 and any
+// has a decimal point, such as "0.1,0,0.5,1". Percent of the image
+// size is used when every value has a percent sign, such as
+// "10%,0%,50%,100%"
+func ParseRect(raw string) (*Rect, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("the rectangle %s is not valid", raw)
+	}
+
+	percent := strings.HasSuffix(strings.TrimSpace(parts[0]), "%")
+
+	var values [4]float64
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if strings.HasSuffix(part, "%") != percent {
+			return nil, fmt.Errorf("the rectangle %s mixes pixels and percent", raw)
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64)
+		if err != nil || value < 0 || !isFinite(value) {
+			return nil, fmt.Errorf("the rectangle %s is not valid", raw)
+		}
+
+		if percent {
+			value /= 100
+		}
+
+		values[i] = value
+	}
+
+	relative := percent || isFractionRect(raw, values)
+
+	return &Rect{
+		X:        values[0],
+		Y:        values[1],
+		Width:    values[2],
+		Height:   values[3],
+		Relative: relative,
+	}, nil
+}
+
+// isFractionRect tells if the values of a rectangle are fractions,
+// which need a decimal point to tell e.g. "0,0,1,1" from pixels
+func isFractionRect(raw string, values [4]float64) bool {
+	if !strings.Contains(raw, ".") {
+		return false
+	}
+
+	for _, value := range values {
+		if value > 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pixels returns the region in pixels, of an image
+// with the given dimensions
+func (r *Rect) pixels(width, height uint) (x, y int, w, h uint) {
+	if !r.Relative {
+		return int(r.X), int(r.Y), uint(r.Width), uint(r.Height)
+	}
+
+	// The edges are rounded rather than the sizes, so that
+	// e.g. the right half of an odd width stays within it
+	x0 := math.Round(r.X * float64(width))
+	y0 := math.Round(r.Y * float64(height))
+	x1 := math.Round((r.X + r.Width) * float64(width))
+	y1 := math.Round((r.Y + r.Height) * float64(height))
+
+	return int(x0), int(y0), uint(math.Max(x1-x0, 0)), uint(math.Max(y1-y0, 0))
+}
+
+// TrimSpec specifies how uniform borders are trimmed
+type TrimSpec struct {
+	// Fuzz is the color distance in percent, from 0 to 100, within
+	// which a pixel is considered part of the border. The border
+	// color is the color of the top left corner
+	Fuzz float64
+
+	// Margin is the number of border pixels kept around the
+	// trimmed image, as far as the image allows
+	Margin uint
+}
+
+// ParseTrimSpec takes a string in the form "fuzz" or "fuzz,margin"
+// and returns a TrimSpec, such as "10,16" for a fuzz of 10% keeping
+// a margin of 16px. The string "true" trims without fuzz
+func ParseTrimSpec(raw string) (*TrimSpec, error) {
+	if raw == "true" {
+		return &TrimSpec{}, nil
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("the trim specification %s is not valid", raw)
+	}
+
+	fuzz, err := strconv.ParseFloat(strings.TrimSuffix(parts[0], "%"), 64)
+	if err != nil || math.IsNaN(fuzz) || fuzz < 0 || fuzz > 100 {
+		return nil, fmt.Errorf("the trim fuzz %s is not valid", parts[0])
+	}
+
+	spec := &TrimSpec{Fuzz: fuzz}
+	if len(parts) == 2 {
+		margin, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("the trim margin %s is not valid", parts[1])
+		}
+
+		spec.Margin = uint(margin)
+	}
+
+	return spec, nil
+}
+
+// DefaultPackshotFuzz is the trim fuzz used by
+// ParsePackshotSpec when none is given
+const DefaultPackshotFuzz = 10
+
+// PackshotSpec specifies how a product image is normalized. The
+// image is trimmed, scaled to fit within Fill percent of the
+// canvas, and centered on it. The canvas is a square when only
+// the width or the height is given
+type PackshotSpec struct {
+	// Fill is the share of the canvas, in percent from 1 to
+	// 100, the trimmed image is scaled to fit within
+	Fill float64
+
+	// Fuzz is the color distance used when trimming, as in TrimSpec
+	Fuzz float64
+}
+
+// ParsePackshotSpec takes a string in the form "fill" or "fill,fuzz"
+// and returns a PackshotSpec, such as "80" to fit the image within
+// 80% of the canvas. The fuzz defaults to DefaultPackshotFuzz
+func ParsePackshotSpec(raw string) (*PackshotSpec, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("the packshot specification %s is not valid", raw)
+	}
+
+	fill, err := strconv.ParseFloat(strings.TrimSuffix(parts[0], "%"), 64)
+	if err != nil || math.IsNaN(fill) || fill < 1 || fill > 100 {
+		return nil, fmt.Errorf("the packshot fill %s is not valid", parts[0])
+	}
+
+	spec := &PackshotSpec{Fill: fill, Fuzz: DefaultPackshotFuzz}
+	if len(parts) == 2 {
+		trim, err := ParseTrimSpec(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		spec.Fuzz = trim.Fuzz
+	}
+
+	return spec, nil
+}
+
+// FrameRange selects a range of frames or pages from a
+// multi-frame source, such as an animated GIF or a TIFF.
+// When the output doesn't support multiple frames, only
+// the first frame of the range is kept
+type FrameRange struct {
+	// First is the zero based index of the first frame
+	First uint
+
+	// Last is the zero based index of the last frame
+	Last uint
+
+	// All selects every frame, ignoring First and Last
+	All bool
+}
+
+// ParseFrameRange takes a string and returns a FrameRange. The
+// string is either a single index such as "2", an inclusive
+// range such as "2-5", or "all" for every frame
+func ParseFrameRange(raw string) (*FrameRange, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "all" {
+		return &FrameRange{All: true}, nil
+	}
+
+	parts := strings.Split(raw, "-")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("the frame range %s is not valid", raw)
+	}
+
+	first, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("the frame range %s is not valid", raw)
+	}
+
+	last := first
+	if len(parts) == 2 {
+		last, err = strconv.ParseUint(parts[1], 10, 32)
+		if err != nil || last < first {
+			return nil, fmt.Errorf("the frame range %s is not valid", raw)
+		}
+	}
+
+	return &FrameRange{
+		First: uint(first),
+		Last:  uint(last),
+	}, nil
 }
 
 // ParseOutputSpec takes a string and returns a valid
@@ -121,8 +646,13 @@ type OutputSpec struct {
 // which should be 200px wide and 100px high. An anchor is also created
 // for a resizing box rule, where the source image should be placed
 // to the upper left corner when resizing the canvas.
+//
+// Options may follow the dimensions, each prefixed by a tilde, such
+// as the resampling filter in "200x100~nearest", or a rotation and
+// mirroring in "200x100~r90~flip~flop".
 func ParseOutputSpec(raw string) (*OutputSpec, error) {
-	parts := strings.Split(strings.ToLower(raw), "@")
+	options := strings.Split(strings.ToLower(raw), "~")
+	parts := strings.Split(options[0], "@")
 	anchor := &Anchor{
 		Horizontal: GravityCenter,
 		Vertical:   GravityCenter,
@@ -153,18 +683,69 @@ func ParseOutputSpec(raw string) (*OutputSpec, error) {
 		return nil, err
 	}
 
-	return &OutputSpec{
+	spec := &OutputSpec{
 		Width:   float64(w),
 		Height:  float64(h),
 		Anchor:  anchor,
 		Crop:    false,
 		Quality: 85,
-	}, nil
+	}
+
+	for _, option := range options[1:] {
+		if err := spec.parseOption(option); err != nil {
+			return nil, err
+		}
+	}
+
+	return spec, nil
+}
+
+// parseOption applies an option following the dimensions
+// of a spec string
+func (s *OutputSpec) parseOption(option string) error {
+	switch {
+	case option == "flip":
+		s.Flip = true
+		return nil
+	case option == "flop":
+		s.Flop = true
+		return nil
+	case strings.HasPrefix(option, "r"):
+		if degrees, err := strconv.ParseFloat(option[1:], 64); err == nil && isFinite(degrees) {
+			s.Rotate = degrees
+			return nil
+		}
+	}
+
+	filter, err := ParseFilter(option)
+	if err != nil {
+		return fmt.Errorf("the spec option %s is not valid", option)
+	}
+
+	s.Filter = filter
+	return nil
 }
 
 // ParseAnchorSpec returns horizontal and vertical anchoring from
 // a string template, where values are separated with a comma.
+// A value of "smart" selects GravitySmart, and a single "smart"
+// selects it for both axes. A focal point is given as fractions
+// of the image width and height, prefixed by "fp:", such as
+// "fp:0.3,0.7". Each value may be followed by an offset in pixels
+// or percent, such as "1+16,1+5%" for 16px from the right edge
+// and 5% from the bottom edge.
 func ParseAnchorSpec(raw string) *Anchor {
+	if strings.HasPrefix(raw, "fp:") {
+		return parseFocalPoint(strings.TrimPrefix(raw, "fp:"))
+	}
+
+	if strings.TrimSpace(raw) == "smart" {
+		return &Anchor{
+			Horizontal: GravitySmart,
+			Vertical:   GravitySmart,
+		}
+	}
+
 	parts := strings.Split(raw, ",")
 	if len(parts) != 2 {
 		return &Anchor{
@@ -173,27 +754,86 @@ func ParseAnchorSpec(raw string) *Anchor {
 		}
 	}
 
-	hz := GravityCenter
-	vt := GravityCenter
-	if x, err := strconv.Atoi(parts[0]); err == nil {
-		if x < 0 {
-			hz = GravityPull
-		}
-		if x > 0 {
-			hz = GravityPush
+	hz, hzOffset := parseAnchorAxis(parts[0])
+	vt, vtOffset := parseAnchorAxis(parts[1])
+
+	return &Anchor{
+		Horizontal: hz,
+		Vertical:   vt,
+		OffsetX:    hzOffset,
+		OffsetY:    vtOffset,
+	}
+}
+
+// parseAnchorAxis parses a gravity with an optional offset,
+// such as "1+16" or "-1+5%". A space is accepted instead of
+// the plus sign, which is how it's decoded from a URL
+func parseAnchorAxis(raw string) (Gravity, Offset) {
+	raw = strings.TrimSpace(raw)
+
+	i := strings.IndexAny(raw, "+ ")
+	if i <= 0 {
+		return parseGravity(raw), Offset{}
+	}
+
+	return parseGravity(raw[:i]), parseOffset(raw[i+1:])
+}
+
+func parseOffset(raw string) Offset {
+	raw = strings.TrimSpace(raw)
+	percent := strings.HasSuffix(raw, "%")
+
+	value, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+	if err != nil {
+		return Offset{}
+	}
+
+	return Offset{
+		Value:   value,
+		Percent: percent,
+	}
+}
+
+func parseFocalPoint(raw string) *Anchor {
+	parts := strings.Split(raw, ",")
+	if len(parts) == 2 {
+		x, errX := strconv.ParseFloat(parts[0], 64)
+		y, errY := strconv.ParseFloat(parts[1], 64)
+
+		if errX == nil && errY == nil && isFinite(x) && isFinite(y) {
+			return &Anchor{
+				Horizontal: GravityFocal,
+				Vertical:   GravityFocal,
+				FocusX:     math.Max(0, math.Min(x, 1)),
+				FocusY:     math.Max(0, math.Min(y, 1)),
+			}
 		}
 	}
-	if x, err := strconv.Atoi(parts[1]); err == nil {
+
+	return &Anchor{
+		Horizontal: GravityCenter,
+		Vertical:   GravityCenter,
+	}
+}
+
+// isFinite tells if a parsed value is neither NaN nor infinite
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func parseGravity(raw string) Gravity {
+	if strings.TrimSpace(raw) == "smart" {
+		return GravitySmart
+	}
+
+	if x, err := strconv.Atoi(raw); err == nil {
 		if x < 0 {
-			vt = GravityPull
+			return GravityPull
 		}
 		if x > 0 {
-			vt = GravityPush
+			return GravityPush
 		}
 	}
 
-	return &Anchor{
-		Horizontal: hz,
-		Vertical:   vt,
-	}
+	return GravityCenter
 }
@@ -215,49 +215,49 @@ func Test_That_ParseOutputSpec_Parses_Only_Height_Dimension(t *testing.T) {
 
 func Test_That_ParseAnchorSpec_Sets_GravityPull_For_Horizontal_Negative_Value(t *testing.T) {
 	raw := "-1,9"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityPull, spec.Horizontal)
 }
 
 func Test_That_ParseAnchorSpec_Sets_GravityCenter_For_Horizontal_Zero_Value(t *testing.T) {
 	raw := "0,9"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityCenter, spec.Horizontal)
 }
 
 func Test_That_ParseAnchorSpec_Sets_GravityPush_For_Horizontal_Positive_Value(t *testing.T) {
 	raw := "1,9"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityPush, spec.Horizontal)
 }
 
 func Test_That_ParseAnchorSpec_Sets_GravityPull_For_Vertical_Negative_Value(t *testing.T) {
 	raw := "9,-1"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityPull, spec.Vertical)
 }
 
 func Test_That_ParseAnchorSpec_Sets_GravityCenter_For_Vertical_Zero_Value(t *testing.T) {
 	raw := "9,0"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityCenter, spec.Vertical)
 }
 
 func Test_That_ParseAnchorSpec_Sets_GravityPush_For_Vertical_Positive_Value(t *testing.T) {
 	raw := "9,1"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityPush, spec.Vertical)
 }
 
 func Test_That_ParseAnchorSpec_Defaults_To_CenterGravity_Values_For_Separator_Error(t *testing.T) {
 	raw := "1"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityCenter, spec.Horizontal)
 	assert.Equal(t, GravityCenter, spec.Vertical)
@@ -265,14 +265,355 @@ func Test_That_ParseAnchorSpec_Defaults_To_CenterGravity_Values_For_Separator_Er
 
 func Test_That_ParseAnchorSpec_Defaults_To_CenterGravity_Horizontal_Value(t *testing.T) {
 	raw := "k,1"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityCenter, spec.Horizontal)
 }
 
 func Test_That_ParseAnchorSpec_Defaults_To_CenterGravity_Vertical_Value(t *testing.T) {
 	raw := "1,k"
-	spec := parseAnchorSpec(raw)
+	spec := ParseAnchorSpec(raw)
 
 	assert.Equal(t, GravityCenter, spec.Vertical)
 }
+
+func Test_That_ParseFrameRange_Parses_Single_Frame(t *testing.T) {
+	r, err := ParseFrameRange("2")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &FrameRange{First: 2, Last: 2}, r)
+}
+
+func Test_That_ParseFrameRange_Parses_Range_Of_Frames(t *testing.T) {
+	r, err := ParseFrameRange("1-4")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &FrameRange{First: 1, Last: 4}, r)
+}
+
+func Test_That_ParseFrameRange_Parses_All_Frames_Ignoring_Case(t *testing.T) {
+	lower, err := ParseFrameRange("all")
+	assert.NoError(t, err)
+
+	upper, err := ParseFrameRange("ALL")
+	assert.NoError(t, err)
+
+	assert.Equal(t, &FrameRange{All: true}, lower)
+	assert.Equal(t, &FrameRange{All: true}, upper)
+}
+
+func Test_That_ParseFrameRange_Returns_Error_For_Reversed_Range(t *testing.T) {
+	r, err := ParseFrameRange("4-1")
+
+	assert.Error(t, err)
+	assert.Nil(t, r)
+}
+
+func Test_That_ParseFrameRange_Returns_Error_For_Negative_Frame(t *testing.T) {
+	r, err := ParseFrameRange("-1")
+
+	assert.Error(t, err)
+	assert.Nil(t, r)
+}
+
+func Test_That_ParseFrameRange_Returns_Error_For_Malformed_Range(t *testing.T) {
+	for _, raw := range []string{"1-2-3", "k"} {
+		r, err := ParseFrameRange(raw)
+
+		assert.Error(t, err, raw)
+		assert.Nil(t, r, raw)
+	}
+}
+
+func Test_That_ParseOutputSpec_Parses_Filter_Option(t *testing.T) {
+	spec, err := ParseOutputSpec("200x100@1,1~nearest")
+
+	assert.NoError(t, err)
+	assert.Equal(t, float64(200), spec.Width)
+	assert.Equal(t, GravityPush, spec.Anchor.Horizontal)
+	assert.Equal(t, FilterNearest, spec.Filter)
+}
+
+func Test_That_ParseOutputSpec_Returns_Error_For_Unknown_Option(t *testing.T) {
+	_, err := ParseOutputSpec("200x100~sharpest")
+
+	assert.Error(t, err)
+}
+
+func Test_That_ParseFitMode_Parses_Fit_Modes(t *testing.T) {
+	modes := map[string]FitMode{
+		"contain": FitContain,
+		"cover":   FitCover,
+		"fill":    FitFill,
+		"inside":  FitInside,
+		"outside": FitOutside,
+	}
+
+	for raw, expected := range modes {
+		mode, err := ParseFitMode(raw)
+
+		assert.NoError(t, err, raw)
+		assert.Equal(t, expected, mode, raw)
+	}
+}
+
+func Test_That_ParseFitMode_Parses_Pad_And_Crop_Aliases(t *testing.T) {
+	pad, err := ParseFitMode("pad")
+	assert.NoError(t, err)
+
+	crop, err := ParseFitMode("crop")
+	assert.NoError(t, err)
+
+	assert.Equal(t, FitContain, pad)
+	assert.Equal(t, FitCover, crop)
+}
+
+func Test_That_ParseFitMode_Ignores_Case(t *testing.T) {
+	mode, err := ParseFitMode("Inside")
+
+	assert.NoError(t, err)
+	assert.Equal(t, FitInside, mode)
+}
+
+func Test_That_ParseFitMode_Returns_Error_For_Unknown_Mode(t *testing.T) {
+	_, err := ParseFitMode("stretch")
+
+	assert.Error(t, err)
+}
+
+func Test_That_WithDevicePixelRatio_Scales_Text_Size_On_A_Copy(t *testing.T) {
+	spec := &OutputSpec{Width: 100, Height: 50, DevicePixelRatio: 2, Text: &TextBlock{FontSize: 12}}
+
+	scaled := spec.withDevicePixelRatio(DefaultMaxDevicePixelRatio)
+
+	assert.Equal(t, float64(200), scaled.Width)
+	assert.Equal(t, float64(100), scaled.Height)
+	assert.Equal(t, float64(24), scaled.Text.FontSize)
+	assert.Equal(t, float64(12), spec.Text.FontSize)
+}
+
+func Test_That_ParseAnchorSpec_Sets_GravitySmart(t *testing.T) {
+	assert.Equal(t, &Anchor{Horizontal: GravitySmart, Vertical: GravityPull}, ParseAnchorSpec("smart,-1"))
+	assert.Equal(t, &Anchor{Horizontal: GravitySmart, Vertical: GravitySmart}, ParseAnchorSpec("smart"))
+}
+
+func Test_That_GetHorizontalAnchorValue_Centers_Focal_Point(t *testing.T) {
+	center := &Anchor{Horizontal: GravityFocal, FocusX: 0.5}
+	left := &Anchor{Horizontal: GravityFocal, FocusX: 0.3}
+
+	assert.Equal(t, 50, center.GetHorizontalAnchorValue(100, 200))
+	assert.Equal(t, 10, left.GetHorizontalAnchorValue(100, 200))
+}
+
+func Test_That_GetHorizontalAnchorValue_Keeps_Focal_Crop_Within_Image(t *testing.T) {
+	near := &Anchor{Horizontal: GravityFocal, FocusX: 0.1}
+	far := &Anchor{Horizontal: GravityFocal, FocusX: 0.9}
+
+	assert.Equal(t, 0, near.GetHorizontalAnchorValue(100, 200))
+	assert.Equal(t, 100, far.GetHorizontalAnchorValue(100, 200))
+}
+
+func Test_That_GetHorizontalAnchorValue_Places_Focal_Point_On_Larger_Canvas(t *testing.T) {
+	center := &Anchor{Horizontal: GravityFocal, FocusX: 0.5}
+	far := &Anchor{Horizontal: GravityFocal, FocusX: 0.9}
+
+	assert.Equal(t, -50, center.GetHorizontalAnchorValue(200, 100))
+	assert.Equal(t, -10, far.GetHorizontalAnchorValue(200, 100))
+}
+
+func Test_That_ParseOutputSpec_Parses_Focal_Point(t *testing.T) {
+	spec, err := ParseOutputSpec("400x300@fp:0.3,0.7")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Anchor{Horizontal: GravityFocal, Vertical: GravityFocal, FocusX: 0.3, FocusY: 0.7}, spec.Anchor)
+}
+
+func Test_That_ParseAnchorSpec_Defaults_To_CenterGravity_For_Malformed_Focal_Point(t *testing.T) {
+	assert.Equal(t, &Anchor{Horizontal: GravityCenter, Vertical: GravityCenter}, ParseAnchorSpec("fp:0.3"))
+}
+
+func Test_That_ParseAnchorSpec_Defaults_To_CenterGravity_For_Non_Finite_Focal_Point(t *testing.T) {
+	center := &Anchor{Horizontal: GravityCenter, Vertical: GravityCenter}
+
+	assert.Equal(t, center, ParseAnchorSpec("fp:NaN,0.5"))
+	assert.Equal(t, center, ParseAnchorSpec("fp:0.5,Inf"))
+	assert.Equal(t, center, ParseAnchorSpec("fp:-Inf,0.5"))
+}
+
+func Test_That_Anchor_Moves_Pulled_Image_Away_From_Top_Left_Edge(t *testing.T) {
+	anchor := &Anchor{Horizontal: GravityPull, OffsetX: Offset{Value: 16}, Vertical: GravityPull, OffsetY: Offset{Value: 16}}
+
+	assert.Equal(t, -16, anchor.GetHorizontalAnchorValue(200, 300))
+	assert.Equal(t, -16, anchor.GetVerticalAnchorValue(200, 300))
+}
+
+func Test_That_Anchor_Moves_Pushed_Image_Away_From_Bottom_Right_Edge(t *testing.T) {
+	anchor := &Anchor{Horizontal: GravityPush, OffsetX: Offset{Value: 16}, Vertical: GravityPush, OffsetY: Offset{Value: 16}}
+
+	assert.Equal(t, 116, anchor.GetHorizontalAnchorValue(200, 300))
+	assert.Equal(t, 116, anchor.GetVerticalAnchorValue(200, 300))
+}
+
+func Test_That_Anchor_Moves_Centered_Image_Toward_Bottom_Right(t *testing.T) {
+	anchor := &Anchor{Horizontal: GravityCenter, OffsetX: Offset{Value: 16}, Vertical: GravityCenter, OffsetY: Offset{Value: 16}}
+
+	assert.Equal(t, 34, anchor.GetHorizontalAnchorValue(200, 300))
+	assert.Equal(t, 34, anchor.GetVerticalAnchorValue(200, 300))
+}
+
+func Test_That_Anchor_Resolves_Percent_Offset_Against_Image_Size(t *testing.T) {
+	anchor := &Anchor{Horizontal: GravityPull, OffsetX: Offset{Value: 5, Percent: true}, Vertical: GravityPull, OffsetY: Offset{Value: 5, Percent: true}}
+
+	assert.Equal(t, -10, anchor.GetHorizontalAnchorValue(200, 300))
+	assert.Equal(t, -10, anchor.GetVerticalAnchorValue(200, 300))
+}
+
+func Test_That_Anchor_Moves_Pushed_Image_Toward_Bottom_Right_Edge_With_Negative_Offset(t *testing.T) {
+	anchor := &Anchor{Horizontal: GravityPush, OffsetX: Offset{Value: -16}, Vertical: GravityPush, OffsetY: Offset{Value: -16}}
+
+	assert.Equal(t, 84, anchor.GetHorizontalAnchorValue(200, 300))
+	assert.Equal(t, 84, anchor.GetVerticalAnchorValue(200, 300))
+}
+
+func Test_That_ParseAnchorSpec_Parses_Offsets(t *testing.T) {
+	expected := &Anchor{
+		Horizontal: GravityPush,
+		Vertical:   GravityPull,
+		OffsetX:    Offset{Value: 16},
+		OffsetY:    Offset{Value: 5, Percent: true},
+	}
+
+	assert.Equal(t, expected, ParseAnchorSpec("1+16,-1+5%"))
+	assert.Equal(t, expected, ParseAnchorSpec("1 16,-1 5%"))
+}
+
+func Test_That_WithDevicePixelRatio_Scales_Pixel_Offsets(t *testing.T) {
+	spec := &OutputSpec{Width: 100, DevicePixelRatio: 2, Anchor: ParseAnchorSpec("1+16,1+5%")}
+
+	scaled := spec.withDevicePixelRatio(DefaultMaxDevicePixelRatio)
+
+	assert.Equal(t, Offset{Value: 32}, scaled.Anchor.OffsetX)
+	assert.Equal(t, Offset{Value: 5, Percent: true}, scaled.Anchor.OffsetY)
+	assert.Equal(t, Offset{Value: 16}, spec.Anchor.OffsetX)
+}
+
+func Test_That_ParseRect_Parses_Pixels(t *testing.T) {
+	rect, err := ParseRect("10,20,300,200")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Rect{X: 10, Y: 20, Width: 300, Height: 200}, rect)
+}
+
+func Test_That_ParseRect_Ignores_Spaces(t *testing.T) {
+	rect, err := ParseRect("0, 0, 100, 100")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Rect{X: 0, Y: 0, Width: 100, Height: 100}, rect)
+}
+
+func Test_That_ParseRect_Parses_Fractional_Pixels(t *testing.T) {
+	rect, err := ParseRect("10.5,20,300.5,200")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Rect{X: 10.5, Y: 20, Width: 300.5, Height: 200}, rect)
+}
+
+func Test_That_ParseRect_Parses_Whole_Numbers_Up_To_One_As_Pixels(t *testing.T) {
+	rect, err := ParseRect("0,0,1,1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Rect{X: 0, Y: 0, Width: 1, Height: 1}, rect)
+}
+
+func Test_That_ParseRect_Parses_Fractions(t *testing.T) {
+	rect, err := ParseRect("0.1,0,0.5,1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Rect{X: 0.1, Y: 0, Width: 0.5, Height: 1, Relative: true}, rect)
+}
+
+func Test_That_ParseRect_Parses_Percent(t *testing.T) {
+	rect, err := ParseRect("10%,0%,50%,100%")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Rect{X: 0.1, Y: 0, Width: 0.5, Height: 1, Relative: true}, rect)
+}
+
+func Test_That_ParseRect_Returns_Error_For_Mixed_Pixels_And_Percent(t *testing.T) {
+	_, err := ParseRect("10%,0,50%,100%")
+
+	assert.Error(t, err)
+}
+
+func Test_That_ParseRect_Returns_Error_For_Wrong_Number_Of_Values(t *testing.T) {
+	for _, raw := range []string{"10,20,300", "0.1,0,0.5,1,0.5,1"} {
+		_, err := ParseRect(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_That_ParseRect_Returns_Error_For_Invalid_Values(t *testing.T) {
+	for _, raw := range []string{"10,-20,300,200", "10,20,300,200px", "NaN,0,1,1", "0,0,Inf,1"} {
+		_, err := ParseRect(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_That_Rect_Pixels_Returns_Pixel_Rect_Unchanged(t *testing.T) {
+	rect := Rect{X: 10, Y: 20, Width: 30, Height: 40}
+
+	x, y, w, h := rect.pixels(100, 100)
+
+	assert.Equal(t, 10, x)
+	assert.Equal(t, 20, y)
+	assert.Equal(t, uint(30), w)
+	assert.Equal(t, uint(40), h)
+}
+
+func Test_That_Rect_Pixels_Keeps_Right_Half_Of_Odd_Width_Within_Image(t *testing.T) {
+	rect := Rect{X: 0.5, Y: 0, Width: 0.5, Height: 1, Relative: true}
+
+	x, y, w, h := rect.pixels(101, 50)
+
+	assert.Equal(t, 51, x)
+	assert.Equal(t, 0, y)
+	assert.Equal(t, uint(50), w)
+	assert.Equal(t, uint(50), h)
+}
+
+func Test_That_Rect_Pixels_Rounds_Relative_Edges(t *testing.T) {
+	corner := Rect{X: 0, Y: 0, Width: 0.5, Height: 0.5, Relative: true}
+	center := Rect{X: 0.25, Y: 0.25, Width: 0.5, Height: 0.5, Relative: true}
+
+	x, y, w, h := corner.pixels(101, 51)
+	assert.Equal(t, []int{0, 0, 51, 26}, []int{x, y, int(w), int(h)})
+
+	x, y, w, h = center.pixels(101, 101)
+	assert.Equal(t, []int{25, 25, 51, 51}, []int{x, y, int(w), int(h)})
+}
+
+func Test_That_ParseOutputSpec_Parses_Transform_Options(t *testing.T) {
+	spec, err := ParseOutputSpec("200x100~r-90~flip~flop~box")
+
+	assert.NoError(t, err)
+	assert.Equal(t, float64(-90), spec.Rotate)
+	assert.True(t, spec.Flip)
+	assert.True(t, spec.Flop)
+	assert.Equal(t, FilterBox, spec.Filter)
+}
+
+func Test_That_ParseOutputSpec_Returns_Error_For_Non_Finite_Rotation(t *testing.T) {
+	for _, raw := range []string{"200x100~rNaN", "200x100~rInf", "200x100~r-Inf"} {
+		_, err := ParseOutputSpec(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_That_Compression_Values_Are_Stable(t *testing.T) {
+	assert.Equal(t, Compression(3), TransitiveCompression)
+	assert.Equal(t, "avif", Avif.String())
+	assert.Equal(t, "", TransitiveCompression.String())
+}
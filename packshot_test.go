@@ -0,0 +1,104 @@
+package improc
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_Apply_Centers_Packshot_On_Square_Canvas(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("200x")
+	spec.Compression = Png
+	spec.Background = Color("#FFFFFF")
+	spec.Packshot = &PackshotSpec{Fill: 80, Fuzz: 10}
+
+	output, err := converter.Apply(testBorderedImage(t, 300, 100, image.Rect(100, 20, 200, 80)), spec)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(output))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 200, 200), img.Bounds())
+
+	// The 100x60 subject is scaled to 160x96, centered on the canvas
+	for _, p := range []image.Point{{100, 100}, {25, 55}, {175, 145}} {
+		r, _, _, _ := img.At(p.X, p.Y).RGBA()
+		assert.True(t, r>>8 < 100, "subject at %v", p)
+	}
+	for _, p := range []image.Point{{15, 100}, {185, 100}, {100, 47}, {100, 153}} {
+		r, _, _, _ := img.At(p.X, p.Y).RGBA()
+		assert.True(t, r>>8 > 200, "background at %v", p)
+	}
+}
+
+func Test_That_Apply_Returns_Error_For_Packshot_Without_Size(t *testing.T) {
+	converter := NewImageConverter()
+	spec := &OutputSpec{Compression: Png, Anchor: &Anchor{}, Packshot: &PackshotSpec{Fill: 80, Fuzz: 10}}
+
+	_, err := converter.Apply(testBorderedImage(t, 300, 100, image.Rect(100, 20, 200, 80)), spec)
+
+	assert.Error(t, err)
+}
+
+func Test_That_Apply_Fills_Whole_Canvas_For_NaN_Packshot_Fill(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("200x")
+	spec.Compression = Png
+	spec.Packshot = &PackshotSpec{Fill: math.NaN(), Fuzz: 10}
+
+	output, err := converter.Apply(testBorderedImage(t, 300, 100, image.Rect(100, 20, 200, 80)), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 200, w)
+	assert.Equal(t, 200, h)
+}
+
+func Test_That_ParsePackshotSpec_Parses_Fill_With_Default_Fuzz(t *testing.T) {
+	spec, err := ParsePackshotSpec("80")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &PackshotSpec{Fill: 80, Fuzz: DefaultPackshotFuzz}, spec)
+}
+
+func Test_That_ParsePackshotSpec_Parses_Fill_With_Percent_Sign(t *testing.T) {
+	spec, err := ParsePackshotSpec("90%")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &PackshotSpec{Fill: 90, Fuzz: DefaultPackshotFuzz}, spec)
+}
+
+func Test_That_ParsePackshotSpec_Parses_Fill_And_Fuzz(t *testing.T) {
+	spec, err := ParsePackshotSpec("80,5")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &PackshotSpec{Fill: 80, Fuzz: 5}, spec)
+}
+
+func Test_That_ParsePackshotSpec_Returns_Error_For_Out_Of_Range_Fill(t *testing.T) {
+	for _, raw := range []string{"0", "120"} {
+		_, err := ParsePackshotSpec(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_That_ParsePackshotSpec_Returns_Error_For_Malformed_Spec(t *testing.T) {
+	for _, raw := range []string{"80,x", "80,,5"} {
+		_, err := ParsePackshotSpec(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_That_ParsePackshotSpec_Returns_Error_For_NaN_Values(t *testing.T) {
+	for _, raw := range []string{"NaN", "80,NaN"} {
+		_, err := ParsePackshotSpec(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,65 @@
+package improc
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const photoshopIPTCResource = 0x0404
+
+var (
+	photoshopHeader    = []byte("Photoshop 3.0\x00")
+	photoshopSignature = []byte("8BIM")
+)
+
+// photoshopIPTC rebuilds a Photoshop 8BIM profile keeping only
+// the IPTC-NAA resource, dropping thumbnails, paths and any
+// other image resources. It returns nil when the profile
+// doesn't contain an IPTC resource
+func photoshopIPTC(profile []byte) []byte {
+	hasHeader := bytes.HasPrefix(profile, photoshopHeader)
+	data := bytes.TrimPrefix(profile, photoshopHeader)
+
+	for len(data) >= 12 && bytes.HasPrefix(data, photoshopSignature) {
+		id := binary.BigEndian.Uint16(data[4:])
+
+		// The resource name is a Pascal string, padded
+		// to an even size including its length byte
+		name := int(data[6]) + 1
+		name += name % 2
+
+		offset := 6 + name
+		if offset+4 > len(data) {
+			return nil
+		}
+
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 4 + size
+		if size < 0 || end > len(data) {
+			return nil
+		}
+
+		if id == photoshopIPTCResource {
+			var out []byte
+			if hasHeader {
+				out = append(out, photoshopHeader...)
+			}
+			out = append(out, data[:end]...)
+			if size%2 != 0 {
+				out = append(out, 0)
+			}
+
+			return out
+		}
+
+		// Resource data is padded to an even size
+		end += size % 2
+		if end > len(data) {
+			return nil
+		}
+
+		data = data[end:]
+	}
+
+	return nil
+}
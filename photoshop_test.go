@@ -0,0 +1,44 @@
+package improc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPhotoshopResource(id uint16, data []byte) []byte {
+	block := append([]byte("8BIM"), 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(block[4:], id)
+	binary.BigEndian.PutUint32(block[8:], uint32(len(data)))
+	block = append(block, data...)
+	if len(data)%2 != 0 {
+		block = append(block, 0)
+	}
+
+	return block
+}
+
+func Test_That_PhotoshopIPTC_Keeps_Only_The_IPTC_Resource(t *testing.T) {
+	iptc := testPhotoshopResource(photoshopIPTCResource, []byte{0x1C, 0x02, 0x74, 0x00, 0x03, 'J', 'D', 'C'})
+	profile := append([]byte{}, photoshopHeader...)
+	profile = append(profile, testPhotoshopResource(0x040C, []byte("thumbnail"))...)
+	profile = append(profile, iptc...)
+	profile = append(profile, testPhotoshopResource(0x0422, []byte("exif"))...)
+
+	result := photoshopIPTC(profile)
+
+	assert.Equal(t, append(append([]byte{}, photoshopHeader...), iptc...), result)
+}
+
+func Test_That_PhotoshopIPTC_Returns_Nil_Without_IPTC_Resource(t *testing.T) {
+	profile := testPhotoshopResource(0x040C, []byte("thumbnail"))
+
+	assert.Nil(t, photoshopIPTC(profile))
+}
+
+func Test_That_PhotoshopIPTC_Returns_Nil_For_Truncated_Profile(t *testing.T) {
+	profile := testPhotoshopResource(photoshopIPTCResource, []byte("iptc"))
+
+	assert.Nil(t, photoshopIPTC(profile[:len(profile)-2]))
+}
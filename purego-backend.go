@@ -0,0 +1,378 @@
+//go:build purego
+// +build purego
+
+package improc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+type puregoBackend struct{}
+
+func newBackend() Backend {
+	return &puregoBackend{}
+}
+
+func (b *puregoBackend) Ping(blob []byte) (*SourceInfo, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(blob))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SourceInfo{
+		Width:  uint(cfg.Width),
+		Height: uint(cfg.Height),
+		Frames: 1,
+	}, nil
+}
+
+func (b *puregoBackend) SetLimits(limits *InputLimits) error {
+	// The header is always pinged before decoding, which
+	// is the only guard available for the standard codecs
+	return nil
+}
+
+func (b *puregoBackend) NewImage() Image {
+	return &puregoImage{}
+}
+
+func (b *puregoBackend) Terminate() {}
+
+// puregoImage is an Image implemented with the standard library
+// codecs, supporting JPEG, PNG and GIF sources. Only the first
+// frame of animated sources is decoded
+type puregoImage struct {
+	img         *image.NRGBA
+	format      string
+	orientation int
+}
+
+func (i *puregoImage) Read(blob []byte) error {
+	src, format, err := image.Decode(bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+
+	i.img = toNRGBA(src)
+	i.format = format
+	i.orientation = exifOrientation(blob)
+
+	return nil
+}
+
+func (i *puregoImage) Format() string {
+	return strings.ToUpper(i.format)
+}
+
+func (i *puregoImage) Frames() uint {
+	// Only the first frame of animated sources is decoded
+	return 1
+}
+
+func (i *puregoImage) SelectFrames(first, last uint) error {
+	if first != 0 || last != 0 {
+		return fmt.Errorf("%w: selecting frames other than the first", ErrUnsupported)
+	}
+
+	return nil
+}
+
+func (i *puregoImage) Width() uint {
+	return uint(i.img.Bounds().Dx())
+}
+
+func (i *puregoImage) Height() uint {
+	return uint(i.img.Bounds().Dy())
+}
+
+func (i *puregoImage) AutoOrient() error {
+	i.img = orient(i.img, i.orientation)
+	i.orientation = 1
+
+	return nil
+}
+
+func (i *puregoImage) ConvertProfile(target, cmykDefault []byte) error {
+	// Embedded profiles aren't read by the standard library
+	// decoders, which yield sRGB compatible pixel data
+	if !isSRGBProfile(target) {
+		return fmt.Errorf("%w: converting to a non-sRGB profile", ErrUnsupported)
+	}
+
+	return nil
+}
+
+func (i *puregoImage) Rotate(degrees float64, background Color) error {
+	if background == "" {
+		background = ColorTransparent
+	}
+
+	switch math.Mod(math.Mod(degrees, 360)+360, 360) {
+	case 0:
+		return nil
+	case 90:
+		i.img = orient(i.img, 6)
+		return nil
+	case 180:
+		i.img = orient(i.img, 3)
+		return nil
+	case 270:
+		i.img = orient(i.img, 8)
+		return nil
+	}
+
+	bg, err := parseColor(background)
+	if err != nil {
+		return err
+	}
+
+	i.img = rotate(i.img, degrees, bg)
+	return nil
+}
+
+func (i *puregoImage) Flip() error {
+	i.img = orient(i.img, 2)
+	return nil
+}
+
+func (i *puregoImage) Flop() error {
+	i.img = orient(i.img, 4)
+	return nil
+}
+
+func (i *puregoImage) Resize(width, height uint, filter Filter) error {
+	if width == 0 || height == 0 {
+		return fmt.Errorf("invalid resize dimensions %dx%d", width, height)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	scaler(filter).Scale(dst, dst.Bounds(), i.img, i.img.Bounds(), draw.Src, nil)
+	i.img = dst
+
+	return nil
+}
+
+func (i *puregoImage) Extent(width, height uint, x, y int) error {
+	dst := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	draw.Draw(dst, dst.Bounds(), i.img, image.Pt(x, y), draw.Src)
+	i.img = dst
+
+	return nil
+}
+
+func (i *puregoImage) Crop(width, height uint, x, y int) error {
+	region := image.Rect(x, y, x+int(width), y+int(height)).Intersect(i.img.Bounds())
+	if region.Empty() {
+		return fmt.Errorf("crop region %dx%d+%d+%d is outside of the image", width, height, x, y)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(dst, dst.Bounds(), i.img, region.Min, draw.Src)
+	i.img = dst
+
+	return nil
+}
+
+func (i *puregoImage) TrimBox(fuzz float64) (image.Rectangle, error) {
+	bounds := i.img.Bounds()
+	border := i.img.NRGBAAt(bounds.Min.X, bounds.Min.Y)
+	box := image.Rectangle{}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if colorDistance(i.img.NRGBAAt(x, y), border) > fuzz {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	if box.Empty() {
+		return bounds, nil
+	}
+
+	return box, nil
+}
+
+func (i *puregoImage) Background(c Color, compression Compression) error {
+	if c == "" {
+		c = ColorTransparent
+	}
+	if compression == Jpeg && c == ColorTransparent {
+		c = Color("#FFFFFF")
+	}
+	if c == ColorTransparent {
+		return nil
+	}
+
+	bg, err := parseColor(c)
+	if err != nil {
+		return err
+	}
+
+	dst := image.NewNRGBA(i.img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), i.img, i.img.Bounds().Min, draw.Over)
+	i.img = dst
+
+	return nil
+}
+
+func (i *puregoImage) Text(tb *TextBlock) error {
+	return ErrUnsupported
+}
+
+func (i *puregoImage) Strip() error {
+	// The standard library encoders never write any
+	// metadata, so there is nothing to strip
+	return nil
+}
+
+func (i *puregoImage) Profile(name string) []byte {
+	// Profiles aren't read by the standard library decoders
+	return nil
+}
+
+func (i *puregoImage) SetProfile(name string, profile []byte) error {
+	return ErrUnsupported
+}
+
+func (i *puregoImage) Pixels() (*image.NRGBA, error) {
+	return i.img, nil
+}
+
+func (i *puregoImage) Encode(quality uint, compression Compression, options *EncoderOptions) ([]byte, error) {
+	format := i.format
+	if compression != TransitiveCompression {
+		format = compression.String()
+	}
+
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case "jpg", "jpeg":
+		if options.Jpeg != (JpegOptions{}) {
+			return nil, fmt.Errorf("%w: JPEG encoder options", ErrUnsupported)
+		}
+
+		q := int(quality)
+		if q == 0 {
+			q = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, i.img, &jpeg.Options{Quality: q})
+	case "png":
+		if options.Png.Filter != PngFilterDefault {
+			return nil, fmt.Errorf("%w: PNG filters", ErrUnsupported)
+		}
+
+		encoder := &png.Encoder{CompressionLevel: pngCompressionLevel(options.Png.CompressionLevel)}
+		err = encoder.Encode(&buf, i.img)
+	case "gif":
+		err = gif.Encode(&buf, i.img, nil)
+	default:
+		return nil, fmt.Errorf("%w: encoding %s", ErrUnsupported, format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (i *puregoImage) Destroy() {
+	i.img = nil
+}
+
+func pngCompressionLevel(level uint) png.CompressionLevel {
+	switch {
+	case level == 0:
+		return png.DefaultCompression
+	case level <= 3:
+		return png.BestSpeed
+	case level >= 7:
+		return png.BestCompression
+	}
+
+	return png.DefaultCompression
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+
+	return dst
+}
+
+// colorDistance returns the distance between two colors,
+// in percent of the largest possible distance
+func colorDistance(a, b color.NRGBA) float64 {
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	da := float64(a.A) - float64(b.A)
+
+	return math.Sqrt((dr*dr+dg*dg+db*db+da*da)/4) / 255 * 100
+}
+
+func parseColor(c Color) (color.NRGBA, error) {
+	if c == ColorTransparent {
+		return color.NRGBA{}, nil
+	}
+
+	hex := strings.TrimPrefix(c.String(), "#")
+	if len(hex) == 3 {
+		hex = fmt.Sprintf("%c%c%c%c%c%c", hex[0], hex[0], hex[1], hex[1], hex[2], hex[2])
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("the color %s is not valid", c)
+	}
+
+	return color.NRGBA{
+		R: uint8(v >> 24),
+		G: uint8(v >> 16),
+		B: uint8(v >> 8),
+		A: uint8(v),
+	}, nil
+}
+
+// rotate rotates src clockwise by an arbitrary angle, onto
+// a canvas fitting the rotated image filled with bg
+func rotate(src *image.NRGBA, degrees float64, bg color.NRGBA) *image.NRGBA {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	w, h := float64(src.Bounds().Dx()), float64(src.Bounds().Dy())
+
+	// Rounding errors mustn't grow the canvas by a pixel
+	width := math.Ceil(math.Abs(w*cos) + math.Abs(h*sin) - 1e-6)
+	height := math.Ceil(math.Abs(w*sin) + math.Abs(h*cos) - 1e-6)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	// Rotates around the center of src, onto the center of dst
+	s2d := f64.Aff3{
+		cos, -sin, width/2 - (cos*w/2 - sin*h/2),
+		sin, cos, height/2 - (sin*w/2 + cos*h/2),
+	}
+	draw.BiLinear.Transform(dst, s2d, src, src.Bounds(), draw.Over, nil)
+
+	return dst
+}
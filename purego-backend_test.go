@@ -0,0 +1,21 @@
+//go:build purego
+// +build purego
+
+package improc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_That_Apply_Returns_ErrUnsupported_For_Avif(t *testing.T) {
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("100x")
+	spec.Compression = Avif
+
+	_, err := converter.Apply(testImage(t, 200, 100), spec)
+
+	assert.True(t, errors.Is(err, ErrUnsupported))
+}
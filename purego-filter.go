@@ -0,0 +1,74 @@
+//go:build purego
+// +build purego
+
+package improc
+
+import (
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	boxKernel = &draw.Kernel{Support: 0.5, At: func(t float64) float64 {
+		return 1
+	}}
+
+	hermiteKernel = &draw.Kernel{Support: 1, At: func(t float64) float64 {
+		return (2*t-3)*t*t + 1
+	}}
+
+	// Mitchell-Netravali with B = C = 1/3
+	mitchellKernel = &draw.Kernel{Support: 2, At: func(t float64) float64 {
+		if t < 1 {
+			return (7*t*t*t - 12*t*t + 16.0/3) / 6
+		}
+		return (-7.0/3*t*t*t + 12*t*t - 20*t + 32.0/3) / 6
+	}}
+
+	lanczos2Kernel = &draw.Kernel{Support: 2, At: lanczos(2)}
+	lanczos3Kernel = &draw.Kernel{Support: 3, At: lanczos(3)}
+
+	gaussianKernel = &draw.Kernel{Support: 1.5, At: func(t float64) float64 {
+		// Sigma 0.5, as used by ImageMagick
+		return math.Exp(-2 * t * t)
+	}}
+)
+
+func lanczos(lobes float64) func(float64) float64 {
+	return func(t float64) float64 {
+		return sinc(t) * sinc(t/lobes)
+	}
+}
+
+func sinc(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+
+	return math.Sin(math.Pi*t) / (math.Pi * t)
+}
+
+// scaler returns the x/image/draw implementation of a Filter
+func scaler(filter Filter) draw.Scaler {
+	switch filter {
+	case FilterNearest:
+		return draw.NearestNeighbor
+	case FilterBox:
+		return boxKernel
+	case FilterTriangle:
+		return draw.BiLinear
+	case FilterHermite:
+		return hermiteKernel
+	case FilterCatmullRom:
+		return draw.CatmullRom
+	case FilterMitchell:
+		return mitchellKernel
+	case FilterLanczos3:
+		return lanczos3Kernel
+	case FilterGaussian:
+		return gaussianKernel
+	}
+
+	return lanczos2Kernel
+}
@@ -0,0 +1,86 @@
+//go:build purego
+// +build purego
+
+package improc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// exifOrientation returns the EXIF orientation tag of a JPEG
+// blob, or 1 (top-left) when the tag can't be found
+func exifOrientation(blob []byte) int {
+	if len(blob) < 4 || blob[0] != 0xFF || blob[1] != 0xD8 {
+		return 1
+	}
+
+	offset := 2
+	for offset+4 <= len(blob) {
+		if blob[offset] != 0xFF {
+			return 1
+		}
+
+		marker := blob[offset+1]
+		length := int(binary.BigEndian.Uint16(blob[offset+2:]))
+		if marker == 0xDA || offset+2+length > len(blob) {
+			// Start of scan, no more metadata segments
+			return 1
+		}
+
+		segment := blob[offset+4 : offset+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, exifHeader) {
+			return tiffOrientation(segment[len(exifHeader):])
+		}
+
+		offset += 2 + length
+	}
+
+	return 1
+}
+
+// orient transforms an image according to an EXIF
+// orientation value, so that it's displayed top-left
+func orient(src *image.NRGBA, orientation int) *image.NRGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	var dst *image.NRGBA
+	var at func(x, y int) (int, int)
+
+	switch orientation {
+	case 2:
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+		at = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+		at = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		dst = image.NewNRGBA(image.Rect(0, 0, w, h))
+		at = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		at = func(x, y int) (int, int) { return y, x }
+	case 6:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		at = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		at = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		dst = image.NewNRGBA(image.Rect(0, 0, h, w))
+		at = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return src
+	}
+
+	b := dst.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			sx, sy := at(x, y)
+			dst.SetNRGBA(x, y, src.NRGBAAt(sx, sy))
+		}
+	}
+
+	return dst
+}
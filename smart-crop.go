@@ -0,0 +1,73 @@
+package improc
+
+import (
+	"image"
+	"math"
+)
+
+// smartCropOffset returns the offset of the crop window of the
+// given size, along the width of the image when horizontal is set
+// or along its height otherwise, which holds the most edge energy.
+// Windows with equal energy are resolved towards the center
+func smartCropOffset(img *image.NRGBA, size int, horizontal bool) int {
+	energy := edgeEnergy(img, horizontal)
+	if size >= len(energy) {
+		return 0
+	}
+
+	// The energy of the window is updated as it slides
+	var sum float64
+	for i := 0; i < size; i++ {
+		sum += energy[i]
+	}
+
+	center := float64(len(energy)-size) / 2
+	best, bestSum := 0, sum
+
+	for offset := 1; offset <= len(energy)-size; offset++ {
+		sum += energy[offset+size-1] - energy[offset-1]
+
+		closer := math.Abs(float64(offset)-center) < math.Abs(float64(best)-center)
+		if sum > bestSum+1e-9 || (math.Abs(sum-bestSum) <= 1e-9 && closer) {
+			best, bestSum = offset, sum
+		}
+	}
+
+	return best
+}
+
+// edgeEnergy returns the sum of the luma gradient magnitudes of
+// every column of the image when horizontal is set, or of every
+// row otherwise
+func edgeEnergy(img *image.NRGBA, horizontal bool) []float64 {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	l := luma(img)
+
+	size := height
+	if horizontal {
+		size = width
+	}
+	energy := make([]float64, size)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := l[y*width+x]
+
+			var gradient float64
+			if x+1 < width {
+				gradient += math.Abs(l[y*width+x+1] - v)
+			}
+			if y+1 < height {
+				gradient += math.Abs(l[(y+1)*width+x] - v)
+			}
+
+			if horizontal {
+				energy[x] += gradient
+			} else {
+				energy[y] += gradient
+			}
+		}
+	}
+
+	return energy
+}
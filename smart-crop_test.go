@@ -0,0 +1,74 @@
+package improc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDetailImage returns a flat gray image with a
+// checkerboard covering the given region
+func testDetailImage(width, height int, detail image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			c := color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+			if (image.Point{x, y}).In(detail) && (x/4+y/4)%2 == 0 {
+				c = color.NRGBA{A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func Test_That_SmartCropOffset_Finds_Detail_Horizontally(t *testing.T) {
+	img := testDetailImage(200, 100, image.Rect(140, 0, 200, 100))
+
+	assert.Equal(t, 100, smartCropOffset(img, 100, true))
+}
+
+func Test_That_SmartCropOffset_Finds_Detail_Vertically(t *testing.T) {
+	img := testDetailImage(100, 200, image.Rect(0, 20, 100, 60))
+
+	offset := smartCropOffset(img, 100, false)
+
+	assert.True(t, offset <= 20)
+}
+
+func Test_That_SmartCropOffset_Centers_Without_Detail(t *testing.T) {
+	img := testDetailImage(200, 100, image.Rectangle{})
+
+	assert.Equal(t, 50, smartCropOffset(img, 100, true))
+}
+
+func Test_That_SmartCropOffset_Returns_Zero_For_Larger_Window(t *testing.T) {
+	img := testDetailImage(50, 50, image.Rectangle{})
+
+	assert.Equal(t, 0, smartCropOffset(img, 100, true))
+}
+
+func Test_That_Apply_Crops_Around_Detail_With_GravitySmart(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testDetailImage(200, 100, image.Rect(140, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+
+	converter := NewImageConverter()
+	spec, _ := ParseOutputSpec("100x100@smart")
+	spec.Compression = Png
+	spec.Crop = true
+
+	output, err := converter.Apply(buf.Bytes(), spec)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(output))
+	assert.NoError(t, err)
+	r, _, _, _ := img.At(93, 1).RGBA()
+	assert.True(t, r < 0x4000)
+}
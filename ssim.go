@@ -0,0 +1,76 @@
+package improc
+
+import (
+	"image"
+)
+
+const (
+	// ssimWindow is the side of the square windows compared
+	ssimWindow = 8
+
+	// The constants stabilizing the division for 8-bit values
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// ssim returns the mean structural similarity of the luma of
+// two equally sized images, from 0 to 1 where 1 is identical.
+// It's computed over non-overlapping windows of 8x8 pixels
+func ssim(a, b *image.NRGBA) float64 {
+	width, height := a.Rect.Dx(), a.Rect.Dy()
+	la, lb := luma(a), luma(b)
+
+	var total float64
+	var windows int
+
+	for y0 := 0; y0 < height; y0 += ssimWindow {
+		for x0 := 0; x0 < width; x0 += ssimWindow {
+			var sa, sb, saa, sbb, sab, n float64
+
+			for y := y0; y < y0+ssimWindow && y < height; y++ {
+				for x := x0; x < x0+ssimWindow && x < width; x++ {
+					va, vb := la[y*width+x], lb[y*width+x]
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+					n++
+				}
+			}
+
+			ma, mb := sa/n, sb/n
+			vara, varb := saa/n-ma*ma, sbb/n-mb*mb
+			cov := sab/n - ma*mb
+
+			total += ((2*ma*mb + ssimC1) * (2*cov + ssimC2)) /
+				((ma*ma + mb*mb + ssimC1) * (vara + varb + ssimC2))
+			windows++
+		}
+	}
+
+	if windows == 0 {
+		return 1
+	}
+
+	return total / float64(windows)
+}
+
+// luma returns the luma of every pixel, with transparent
+// pixels blended over white
+func luma(img *image.NRGBA) []float64 {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	values := make([]float64, 0, width*height)
+
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			p := row[x*4 : x*4+4]
+			alpha := float64(p[3]) / 255
+			l := 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+			values = append(values, l*alpha+255*(1-alpha))
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,46 @@
+package improc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testGradient(width, height int, noise uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x) + uint8(x*y)%(noise+1), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	return img
+}
+
+func Test_That_SSIM_Of_Identical_Images_Is_One(t *testing.T) {
+	img := testGradient(20, 20, 0)
+
+	assert.InDelta(t, 1, ssim(img, img), 1e-9)
+}
+
+func Test_That_SSIM_Decreases_With_Distortion(t *testing.T) {
+	reference := testGradient(20, 20, 0)
+
+	slight := ssim(reference, testGradient(20, 20, 4))
+	heavy := ssim(reference, testGradient(20, 20, 64))
+
+	assert.True(t, slight < 1)
+	assert.True(t, heavy < slight)
+}
+
+func Test_That_SSIM_Blends_Transparency_Over_White(t *testing.T) {
+	transparent := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	white := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+
+	assert.InDelta(t, 1, ssim(transparent, white), 1e-9)
+}
@@ -0,0 +1,133 @@
+package improc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testBorderedImage returns a white PNG image with a gray subject
+// covering the given region, and a slightly off-white pixel in
+// every corner but the top left
+func testBorderedImage(t *testing.T, width, height int, subject image.Rectangle) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, subject, image.NewUniform(color.Gray{Y: 64}), image.Point{}, draw.Src)
+
+	offWhite := color.NRGBA{R: 245, G: 245, B: 245, A: 255}
+	img.Set(width-1, 0, offWhite)
+	img.Set(0, height-1, offWhite)
+	img.Set(width-1, height-1, offWhite)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func Test_That_Apply_Trims_Border_Within_Fuzz(t *testing.T) {
+	converter := NewImageConverter()
+	spec := &OutputSpec{Width: 100, Compression: Png, Anchor: &Anchor{}, Trim: &TrimSpec{Fuzz: 10}}
+
+	output, err := converter.Apply(testBorderedImage(t, 200, 100, image.Rect(50, 20, 150, 80)), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 100, w)
+	assert.Equal(t, 60, h)
+}
+
+func Test_That_Apply_Keeps_Border_Outside_Fuzz(t *testing.T) {
+	converter := NewImageConverter()
+	spec := &OutputSpec{Width: 200, Compression: Png, Anchor: &Anchor{}, Trim: &TrimSpec{}}
+
+	output, err := converter.Apply(testBorderedImage(t, 200, 100, image.Rect(50, 20, 150, 80)), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 200, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_Apply_Keeps_Trim_Margin(t *testing.T) {
+	converter := NewImageConverter()
+	spec := &OutputSpec{Width: 120, Compression: Png, Anchor: &Anchor{}, Trim: &TrimSpec{Fuzz: 10, Margin: 10}}
+
+	output, err := converter.Apply(testBorderedImage(t, 200, 100, image.Rect(50, 20, 150, 80)), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 120, w)
+	assert.Equal(t, 80, h)
+}
+
+func Test_That_Apply_Limits_Trim_Margin_To_Image(t *testing.T) {
+	converter := NewImageConverter()
+	spec := &OutputSpec{Width: 160, Compression: Png, Anchor: &Anchor{}, Trim: &TrimSpec{Fuzz: 10, Margin: 30}}
+
+	output, err := converter.Apply(testBorderedImage(t, 200, 100, image.Rect(50, 20, 150, 80)), spec)
+
+	assert.NoError(t, err)
+	w, h := outputSize(t, output)
+	assert.Equal(t, 160, w)
+	assert.Equal(t, 100, h)
+}
+
+func Test_That_ParseTrimSpec_Parses_Default_Trim(t *testing.T) {
+	spec, err := ParseTrimSpec("true")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &TrimSpec{}, spec)
+}
+
+func Test_That_ParseTrimSpec_Parses_Fuzz(t *testing.T) {
+	spec, err := ParseTrimSpec("10")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &TrimSpec{Fuzz: 10}, spec)
+}
+
+func Test_That_ParseTrimSpec_Parses_Fuzz_With_Percent_Sign(t *testing.T) {
+	spec, err := ParseTrimSpec("10%")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &TrimSpec{Fuzz: 10}, spec)
+}
+
+func Test_That_ParseTrimSpec_Parses_Fuzz_And_Margin(t *testing.T) {
+	spec, err := ParseTrimSpec("2.5,16")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &TrimSpec{Fuzz: 2.5, Margin: 16}, spec)
+}
+
+func Test_That_ParseTrimSpec_Returns_Error_For_Out_Of_Range_Values(t *testing.T) {
+	for _, raw := range []string{"101", "10,-16"} {
+		_, err := ParseTrimSpec(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_That_ParseTrimSpec_Returns_Error_For_Malformed_Spec(t *testing.T) {
+	for _, raw := range []string{"10,1,2", "fuzzy"} {
+		_, err := ParseTrimSpec(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
+
+func Test_That_ParseTrimSpec_Returns_Error_For_NaN_Fuzz(t *testing.T) {
+	for _, raw := range []string{"NaN", "NaN,16"} {
+		_, err := ParseTrimSpec(raw)
+
+		assert.Error(t, err, raw)
+	}
+}
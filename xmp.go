@@ -0,0 +1,171 @@
+package improc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+const (
+	xmlNamespace       = "http://www.w3.org/XML/1998/namespace"
+	rdfNamespace       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	dcNamespace        = "http://purl.org/dc/elements/1.1/"
+	xmpRightsNamespace = "http://ns.adobe.com/xap/1.0/rights/"
+	photoshopNamespace = "http://ns.adobe.com/photoshop/1.0/"
+)
+
+// xmpPrefixes are the prefixes used when writing the
+// namespaces which may appear in a filtered XMP profile
+var xmpPrefixes = map[string]string{
+	xmlNamespace:       "xml",
+	rdfNamespace:       "rdf",
+	dcNamespace:        "dc",
+	xmpRightsNamespace: "xmpRights",
+	photoshopNamespace: "photoshop",
+}
+
+var (
+	rdfRoot        = xml.Name{Space: rdfNamespace, Local: "RDF"}
+	rdfDescription = xml.Name{Space: rdfNamespace, Local: "Description"}
+)
+
+// isXMPRightsProperty tells if an XMP property describes
+// the rights holder, the credit or the rights statement
+func isXMPRightsProperty(n xml.Name) bool {
+	switch n.Space {
+	case xmpRightsNamespace:
+		return true
+	case dcNamespace:
+		return n.Local == "rights" || n.Local == "creator"
+	case photoshopNamespace:
+		return n.Local == "Credit" || n.Local == "Source"
+	}
+
+	return false
+}
+
+// xmpRights rebuilds an XMP profile keeping only the
+// dc:rights, dc:creator, xmpRights:*, photoshop:Credit and
+// photoshop:Source properties. It returns nil when the
+// profile can't be parsed or contains none of them
+func xmpRights(profile []byte) []byte {
+	var body bytes.Buffer
+	var stack []xml.Name
+
+	d := xml.NewDecoder(bytes.NewReader(profile))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := len(stack) - 1
+			if t.Name == rdfDescription && parent >= 0 && stack[parent] == rdfRoot {
+				// Simple properties may be written as
+				// attributes of the description
+				for _, a := range t.Attr {
+					if isXMPRightsProperty(a.Name) {
+						writeXMPStart(&body, a.Name, nil)
+						xml.EscapeText(&body, []byte(a.Value))
+						writeXMPEnd(&body, a.Name)
+					}
+				}
+			} else if parent >= 1 && stack[parent] == rdfDescription && stack[parent-1] == rdfRoot {
+				if !isXMPRightsProperty(t.Name) {
+					if err := d.Skip(); err != nil {
+						return nil
+					}
+					continue
+				}
+
+				if err := copyXMPElement(&body, d, t); err != nil {
+					return nil
+				}
+				continue
+			}
+
+			stack = append(stack, t.Name)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if body.Len() == 0 {
+		return nil
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>")
+	out.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">`)
+	out.WriteString(`<rdf:RDF xmlns:rdf="` + rdfNamespace + `">`)
+	out.WriteString(`<rdf:Description rdf:about=""`)
+	out.WriteString(` xmlns:dc="` + dcNamespace + `"`)
+	out.WriteString(` xmlns:xmpRights="` + xmpRightsNamespace + `"`)
+	out.WriteString(` xmlns:photoshop="` + photoshopNamespace + `">`)
+	out.Write(body.Bytes())
+	out.WriteString(`</rdf:Description></rdf:RDF></x:xmpmeta>`)
+	out.WriteString(`<?xpacket end="w"?>`)
+
+	return out.Bytes()
+}
+
+// copyXMPElement writes an element and its content, dropping
+// any nested elements or attributes in unknown namespaces
+func copyXMPElement(w *bytes.Buffer, d *xml.Decoder, start xml.StartElement) error {
+	writeXMPStart(w, start.Name, start.Attr)
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if _, ok := xmpPrefixes[t.Name.Space]; !ok {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := copyXMPElement(w, d, t); err != nil {
+				return err
+			}
+		case xml.CharData:
+			// Whitespace between nested elements isn't part of a value
+			if len(bytes.TrimSpace(t)) > 0 {
+				xml.EscapeText(w, t)
+			}
+		case xml.EndElement:
+			writeXMPEnd(w, start.Name)
+			return nil
+		}
+	}
+}
+
+func writeXMPStart(w *bytes.Buffer, name xml.Name, attrs []xml.Attr) {
+	w.WriteString("<" + xmpPrefixes[name.Space] + ":" + name.Local)
+	for _, a := range attrs {
+		prefix, ok := xmpPrefixes[a.Name.Space]
+		if !ok {
+			continue
+		}
+
+		w.WriteString(" " + prefix + ":" + a.Name.Local + `="`)
+		xml.EscapeText(w, []byte(a.Value))
+		w.WriteString(`"`)
+	}
+	w.WriteString(">")
+}
+
+func writeXMPEnd(w *bytes.Buffer, name xml.Name) {
+	w.WriteString("</" + xmpPrefixes[name.Space] + ":" + name.Local + ">")
+}
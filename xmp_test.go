@@ -0,0 +1,58 @@
+package improc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    photoshop:Credit="Studio &amp; Co"
+    photoshop:City="Gothenburg"
+    exif:GPSLatitude="57,42.1N">
+   <dc:rights>
+    <rdf:Alt>
+     <rdf:li xml:lang="x-default">(c) Jane Doe</rdf:li>
+    </rdf:Alt>
+   </dc:rights>
+   <dc:description>Taken at home</dc:description>
+   <exif:GPSLongitude>11,58.2E</exif:GPSLongitude>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func Test_That_XMPRights_Keeps_Rights_Properties(t *testing.T) {
+	result := string(xmpRights([]byte(testXMP)))
+
+	assert.Contains(t, result, `<photoshop:Credit>Studio &amp; Co</photoshop:Credit>`)
+	assert.Contains(t, result, `<dc:rights><rdf:Alt><rdf:li xml:lang="x-default">(c) Jane Doe</rdf:li></rdf:Alt></dc:rights>`)
+}
+
+func Test_That_XMPRights_Drops_GPS_And_Other_Properties(t *testing.T) {
+	result := string(xmpRights([]byte(testXMP)))
+
+	assert.NotContains(t, result, "GPS")
+	assert.NotContains(t, result, "57,42.1N")
+	assert.NotContains(t, result, "11,58.2E")
+	assert.NotContains(t, result, "Gothenburg")
+	assert.NotContains(t, result, "Taken at home")
+}
+
+func Test_That_XMPRights_Returns_Nil_Without_Rights_Properties(t *testing.T) {
+	xmp := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about="" xmlns:exif="http://ns.adobe.com/exif/1.0/" exif:GPSLatitude="57,42.1N"/>` +
+		`</rdf:RDF></x:xmpmeta>`
+
+	assert.Nil(t, xmpRights([]byte(xmp)))
+}
+
+func Test_That_XMPRights_Returns_Nil_For_Invalid_XML(t *testing.T) {
+	assert.Nil(t, xmpRights([]byte("<x:xmpmeta><rdf:RDF>")))
+}